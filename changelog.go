@@ -0,0 +1,285 @@
+package waffle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	// DefChangelogFile is the default filename for the project changelog
+	DefChangelogFile = "CHANGELOG.md"
+
+	// UnreleasedTitle is the heading used for changes that have not
+	// been tagged yet
+	UnreleasedTitle = "Unreleased"
+)
+
+// changeTypes maps Conventional Commit types to changelog headings.  The
+// order of the slice is the order the headings are written in
+var changeTypes = []struct {
+	Type    string
+	Heading string
+}{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance Improvements"},
+	{"refactor", "Refactoring"},
+	{"revert", "Reverts"},
+	{"docs", "Documentation"},
+	{"style", "Styles"},
+	{"test", "Tests"},
+	{"build", "Build System"},
+	{"ci", "Continuous Integration"},
+	{"chore", "Chores"},
+}
+
+const (
+	breakingHeading = "Breaking Changes"
+	otherHeading    = "Other Changes"
+)
+
+var conventionalRe = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^)]*)\))?(!)?: (.+)$`)
+
+// Change is a single commit in the changelog
+type Change struct {
+	// Type is the Conventional Commit type (ie feat or fix).  Type is
+	// empty for commits that don't follow the Conventional Commit format
+	Type string
+	// Scope is the optional Conventional Commit scope
+	Scope string
+	// Subject is the first line of the commit message (minus the type
+	// and scope)
+	Subject string
+	// Breaking indicates the commit was marked with "!" or has a
+	// BREAKING CHANGE footer
+	Breaking bool
+	// Hash is the commit hash
+	Hash plumbing.Hash
+}
+
+// ParseChange parses the commit message according to the
+// Conventional Commits specification
+func ParseChange(hash plumbing.Hash, msg string) Change {
+	change := Change{Hash: hash}
+	lines := strings.Split(strings.TrimSpace(msg), "\n")
+	change.Subject = strings.TrimSpace(lines[0])
+	if matches := conventionalRe.FindStringSubmatch(change.Subject); matches != nil {
+		change.Type = strings.ToLower(matches[1])
+		change.Scope = matches[2]
+		change.Breaking = matches[3] == "!"
+		change.Subject = matches[4]
+	}
+
+	for _, line := range lines[1:] {
+		if strings.HasPrefix(line, "BREAKING CHANGE:") || strings.HasPrefix(line, "BREAKING-CHANGE:") {
+			change.Breaking = true
+		}
+	}
+	return change
+}
+
+// heading returns the changelog heading the change is listed under
+func (c Change) heading() string {
+	for _, ct := range changeTypes {
+		if ct.Type == c.Type {
+			return ct.Heading
+		}
+	}
+	return otherHeading
+}
+
+func (c Change) String() string {
+	str := c.Subject
+	if c.Scope != "" {
+		str = fmt.Sprintf("**%s:** %s", c.Scope, str)
+	}
+	return fmt.Sprintf("%s (%s)", str, c.Hash.String()[:7])
+}
+
+// ChangelogSection is the list of changes that make up a release
+type ChangelogSection struct {
+	// Title is either the release tag or UnreleasedTitle
+	Title string
+	// Date is the date of the release.  Date is zero for unreleased changes
+	Date time.Time
+	// Changes is the list of commits in the release, newest first
+	Changes []Change
+}
+
+// Write renders the section as markdown
+func (cs ChangelogSection) Write(w io.Writer) {
+	if cs.Date.IsZero() {
+		fmt.Fprintf(w, "## %s\n", cs.Title)
+	} else {
+		fmt.Fprintf(w, "## %s - %s\n", cs.Title, cs.Date.Format("2006-01-02"))
+	}
+
+	groups := make(map[string][]Change)
+	for _, change := range cs.Changes {
+		if change.Breaking {
+			groups[breakingHeading] = append(groups[breakingHeading], change)
+		}
+		groups[change.heading()] = append(groups[change.heading()], change)
+	}
+
+	headings := []string{breakingHeading}
+	for _, ct := range changeTypes {
+		headings = append(headings, ct.Heading)
+	}
+	headings = append(headings, otherHeading)
+
+	for _, heading := range headings {
+		if len(groups[heading]) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "\n### %s\n\n", heading)
+		for _, change := range groups[heading] {
+			fmt.Fprintf(w, "- %s\n", change.String())
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// commitsBetween returns the commits reachable from "to" that are
+// not reachable from "from".  If from is nil then all of the commits
+// reachable from "to" are returned
+func commitsBetween(from, to *object.Commit) (commits []*object.Commit, err error) {
+	seen := make(map[plumbing.Hash]bool)
+	if from != nil {
+		err = object.NewCommitPreorderIter(from, nil, nil).ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			return nil
+		})
+	}
+
+	if err == nil {
+		err = object.NewCommitPreorderIter(to, seen, nil).ForEach(func(c *object.Commit) error {
+			commits = append(commits, c)
+			return nil
+		})
+	}
+	return
+}
+
+func newSection(title string, from, to *object.Commit) (section ChangelogSection, err error) {
+	section.Title = title
+	commits, err := commitsBetween(from, to)
+	for _, commit := range commits {
+		section.Changes = append(section.Changes, ParseChange(commit.Hash, commit.Message))
+	}
+	return
+}
+
+// Changelog groups the commits in the repo by release.  The first
+// section contains any commits since the nearest release and is only
+// included if there are such commits.  The remaining sections are
+// ordered from newest to oldest release
+func (gr *GitRepo) Changelog() (sections []ChangelogSection, err error) {
+	releases, err := gr.Releases()
+	if err != nil {
+		return nil, err
+	}
+
+	var prev *object.Commit
+	for _, release := range releases {
+		var section ChangelogSection
+		section, err = newSection(release.Tag, prev, release.Commit)
+		if err != nil {
+			return nil, err
+		}
+		section.Date = release.Commit.Committer.When
+		sections = append([]ChangelogSection{section}, sections...)
+		prev = release.Commit
+	}
+
+	unreleased, err := gr.Unreleased()
+	if err == nil && len(unreleased.Changes) > 0 {
+		sections = append([]ChangelogSection{unreleased}, sections...)
+	}
+	return sections, err
+}
+
+// Unreleased returns the changes between HEAD and the nearest release
+// reachable from it.  Releases on other branches are ignored, even if
+// they have a higher version
+func (gr *GitRepo) Unreleased() (section ChangelogSection, err error) {
+	d, err := gr.Describe()
+	if err == nil {
+		var prev *object.Commit
+		if d.Release != nil {
+			prev = d.Release.Commit
+		}
+		section, err = newSection(UnreleasedTitle, prev, d.Commit)
+	}
+	return section, err
+}
+
+// WriteChangelog writes a complete changelog consisting of a
+// top level heading followed by each section
+func WriteChangelog(w io.Writer, sections []ChangelogSection) {
+	fmt.Fprintf(w, "# Changelog\n\n")
+	for _, section := range sections {
+		section.Write(w)
+	}
+}
+
+// UpdateChangelog replaces the unreleased section of an existing
+// changelog file.  If the file doesn't have an unreleased section
+// one is inserted before the first release.  If the file doesn't
+// exist it is created
+func UpdateChangelog(filename string, unreleased ChangelogSection) error {
+	content, err := ioutil.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		content, err = []byte("# Changelog\n\n"), nil
+	} else if err != nil {
+		return fmt.Errorf("Failed to read %q: %w", filename, err)
+	}
+
+	section := &bytes.Buffer{}
+	unreleased.Write(section)
+
+	output := &bytes.Buffer{}
+	inserted := false
+	skipping := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "## ") {
+			skipping = false
+			title := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "## ")), "[]")
+			if strings.EqualFold(title, UnreleasedTitle) {
+				skipping = true
+			}
+
+			if !inserted {
+				output.Write(section.Bytes())
+				inserted = true
+			}
+		}
+
+		if !skipping {
+			fmt.Fprintln(output, line)
+		}
+	}
+
+	if err = scanner.Err(); err == nil {
+		if !inserted {
+			output.Write(section.Bytes())
+		}
+
+		err = WriteFile(filename, output.Bytes())
+	}
+	return err
+}
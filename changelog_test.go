@@ -0,0 +1,126 @@
+package waffle
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+var testHash = plumbing.NewHash("abcdef1234567890abcdef1234567890abcdef12")
+
+func TestParseChange(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want Change
+	}{
+		{"feat: add widgets", Change{Type: "feat", Subject: "add widgets"}},
+		{"fix(api): handle nil", Change{Type: "fix", Scope: "api", Subject: "handle nil"}},
+		{"FEAT: upper case type", Change{Type: "feat", Subject: "upper case type"}},
+		{"feat!: drop v1", Change{Type: "feat", Subject: "drop v1", Breaking: true}},
+		{"refactor(config)!: rename fields", Change{Type: "refactor", Scope: "config", Subject: "rename fields", Breaking: true}},
+		{"fix: y\n\nBREAKING CHANGE: x is gone", Change{Type: "fix", Subject: "y", Breaking: true}},
+		{"fix: y\n\nBREAKING-CHANGE: x is gone", Change{Type: "fix", Subject: "y", Breaking: true}},
+		{"fix: y\n\nmentions a BREAKING CHANGE: in the body", Change{Type: "fix", Subject: "y"}},
+		{"  docs: trimmed  \n", Change{Type: "docs", Subject: "trimmed"}},
+		{"Merge branch 'side'", Change{Subject: "Merge branch 'side'"}},
+		{"fix:missing space", Change{Subject: "fix:missing space"}},
+		{"fix(): empty scope", Change{Type: "fix", Subject: "empty scope"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.msg, func(t *testing.T) {
+			test.want.Hash = testHash
+			got := ParseChange(testHash, test.msg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("Wanted %+v got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestUpdateChangelog(t *testing.T) {
+	unreleased := ChangelogSection{
+		Title: UnreleasedTitle,
+		Changes: []Change{
+			{Type: "feat", Scope: "api", Subject: "add widgets", Breaking: true, Hash: testHash},
+			{Type: "fix", Subject: "handle nil", Hash: testHash},
+			{Subject: "Merge branch 'side'", Hash: testHash},
+		},
+	}
+
+	section := "## Unreleased\n\n" +
+		"### Breaking Changes\n\n- **api:** add widgets (abcdef1)\n\n" +
+		"### Features\n\n- **api:** add widgets (abcdef1)\n\n" +
+		"### Bug Fixes\n\n- handle nil (abcdef1)\n\n" +
+		"### Other Changes\n\n- Merge branch 'side' (abcdef1)\n\n"
+
+	release := "## v1.0.0 - 2021-10-18\n\n### Features\n\n- first (1234567)\n\n"
+
+	tests := []struct {
+		name     string
+		existing *string
+		want     string
+	}{
+		{"new file", nil, "# Changelog\n\n" + section},
+		{"no releases", strPtr("# Changelog\n\n"), "# Changelog\n\n" + section},
+		{"inserted before the first release", strPtr("# Changelog\n\n" + release), "# Changelog\n\n" + section + release},
+		{"replaced", strPtr("# Changelog\n\n## Unreleased\n\n- old (7654321)\n\n" + release), "# Changelog\n\n" + section + release},
+		{"bracketed title", strPtr("# Changelog\n\n## [Unreleased]\n\n- old (7654321)\n\n" + release), "# Changelog\n\n" + section + release},
+		{"lower case title", strPtr("# Changelog\n\n## unreleased\n\n- old (7654321)\n\n" + release), "# Changelog\n\n" + section + release},
+		{"preamble kept", strPtr("# Changelog\n\nAll notable changes.\n\n" + release), "# Changelog\n\nAll notable changes.\n\n" + section + release},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), DefChangelogFile)
+			if test.existing != nil {
+				writeTestFile(t, filename, *test.existing)
+			}
+
+			if err := UpdateChangelog(filename, unreleased); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got := readTestFile(t, filename); got != test.want {
+				t.Errorf("Wanted %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestUnreleased(t *testing.T) {
+	tests := []struct {
+		head string
+		want []string
+	}{
+		{"B", nil},
+		{"D", []string{"D", "C"}},
+		{"S2", []string{"S2"}},
+		{"M", []string{"M", "T1"}},
+	}
+
+	th := newDescribeHistory(t)
+	for _, test := range tests {
+		t.Run(test.head, func(t *testing.T) {
+			section, err := th.checkout(test.head).Unreleased()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, change := range section.Changes {
+				got = append(got, change.Subject)
+			}
+
+			if section.Title != UnreleasedTitle {
+				t.Errorf("Wanted %q got %q", UnreleasedTitle, section.Title)
+			}
+
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("Wanted %v got %v", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/abates/waffle"
+)
+
+var exportVersion string
+
+func init() {
+	apiCmd := app.AddCommand("api", "work with the project OpenAPI document", nil)
+	validateCmd := apiCmd.AddCommand("validate", "validate the OpenAPI document", validateAPI)
+	validateCmd.UsageStr = "[file]"
+
+	exportCmd := apiCmd.AddCommand("export", "export a copy of the OpenAPI document for a specific OpenAPI version", exportAPI)
+	exportCmd.UsageStr = "<file>"
+	exportCmd.Flags.StringVar(&exportVersion, "openapi", "3.0", "OpenAPI version to export (3.0 or 3.1)")
+}
+
+func validateAPI(args ...string) error {
+	filename := filepath.Join(root, waffle.DefAPIFile)
+	if len(args) == 1 {
+		filename = args[0]
+	} else if len(args) > 1 {
+		return fmt.Errorf("%w: expecting at most one file", waffle.ErrUsage)
+	}
+
+	err := waffle.ValidateAPIFile(filename)
+	var apiErr waffle.APIValidationError
+	if errors.As(err, &apiErr) {
+		for _, e := range apiErr {
+			log.Logf("<fail>%v</fail>", e)
+		}
+		err = fmt.Errorf("%s has %d error(s)", filename, len(apiErr))
+	} else if err == nil {
+		log.Logf("<success>%s</success> is valid", filename)
+	}
+	return err
+}
+
+func exportAPI(args ...string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expecting an output file", waffle.ErrUsage)
+	}
+
+	warnings, err := config().ExportAPI(args[0], exportVersion)
+	for _, warning := range warnings {
+		log.Logf("<warn>Warning</warn>: %s", warning)
+	}
+
+	if err == nil {
+		log.Logf("<success>%s</success>", args[0])
+	}
+	return err
+}
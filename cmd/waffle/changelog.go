@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	"github.com/abates/waffle"
+)
+
+var unreleasedOnly bool
+var changelogFile string
+
+func init() {
+	cmd := app.AddCommand("changelog", "generate the changelog from the git history", changelogCmd)
+	cmd.Flags.BoolVar(&unreleasedOnly, "unreleased", false, "Only update the unreleased section of the changelog")
+	cmd.Flags.StringVar(&changelogFile, "file", filepath.Join(root, waffle.DefChangelogFile), "Changelog filename")
+}
+
+func changelogCmd(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", waffle.ErrUsage, args)
+	}
+
+	repo, err := waffle.OpenGit(root)
+	if err != nil {
+		return err
+	}
+
+	if unreleasedOnly {
+		var section waffle.ChangelogSection
+		section, err = repo.Unreleased()
+		if err == nil {
+			err = waffle.UpdateChangelog(changelogFile, section)
+		}
+	} else {
+		var sections []waffle.ChangelogSection
+		sections, err = repo.Changelog()
+		if err == nil {
+			buf := &bytes.Buffer{}
+			waffle.WriteChangelog(buf, sections)
+			err = waffle.WriteFile(changelogFile, buf.Bytes())
+		}
+	}
+
+	if err == nil {
+		log.Logf("<success>%s</success>", changelogFile)
+	}
+	return err
+}
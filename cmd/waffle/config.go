@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/abates/waffle"
+)
+
+func init() {
+	configCmd := app.AddCommand("config", "get and set project config fields", nil)
+
+	getCmd := configCmd.AddCommand("get", "print the value of a config field", getField)
+	getCmd.UsageStr = "<field>"
+
+	setCmd := configCmd.AddCommand("set", "change the value of a config field", setField)
+	setCmd.UsageStr = "<field> <value>"
+
+	unsetCmd := configCmd.AddCommand("unset", "clear a config field or remove an item from a list", unsetField)
+	unsetCmd.UsageStr = "<field>"
+
+	configCmd.AddCommand("schema", "print the JSON Schema for the project config", printSchema)
+}
+
+func getField(args ...string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expecting a field such as maintainer.email", waffle.ErrUsage)
+	}
+
+	value, err := config().GetField(args[0])
+	if err == nil {
+		fmt.Println(value)
+	}
+	return err
+}
+
+func setField(args ...string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%w: expecting a field and a value", waffle.ErrUsage)
+	}
+
+	err := config().SetField(args[0], args[1])
+	if err == nil {
+		err = config().SaveDef()
+	}
+
+	if err == nil {
+		log.Logf("Set <hl>%s</hl>", args[0])
+	}
+	return err
+}
+
+func unsetField(args ...string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expecting a field such as maintainer.email", waffle.ErrUsage)
+	}
+
+	err := config().UnsetField(args[0])
+	if err == nil {
+		err = config().SaveDef()
+	}
+
+	if err == nil {
+		log.Logf("Unset <hl>%s</hl>", args[0])
+	}
+	return err
+}
+
+func printSchema(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", waffle.ErrUsage, args)
+	}
+
+	content, err := json.MarshalIndent(waffle.ConfigSchema(), "", "  ")
+	if err == nil {
+		fmt.Println(string(content))
+	}
+	return err
+}
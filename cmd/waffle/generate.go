@@ -2,18 +2,96 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/abates/waffle"
 )
 
+var genEnv string
+var genCheck bool
+
 func init() {
-	app.AddCommand("generate", "(re)generate all code for the project", genCmd)
+	cmd := app.AddCommand("generate", "(re)generate all code for the project", genCmd)
+	cmd.Flags.StringVar(&genEnv, "env", "", "Environment to generate config defaults for (default is the last generated environment or \""+waffle.DefEnvironment+"\")")
+	cmd.Flags.BoolVar(&genCheck, "check", false, "Fail if generating would change any files, without changing them")
+	addWorktreeFlags(cmd, true)
 }
 
-func genCmd(args ...string) (err error) {
+func genCmd(args ...string) error {
 	if len(args) > 0 {
 		return fmt.Errorf("unexpected argument %q", args)
 	}
 
-	return waffle.ExecuteTemplates("generate", ".", *config())
+	if genCheck {
+		return checkGenerated()
+	}
+
+	err := checkWorktree()
+	if err == nil {
+		err = generate()
+	}
+
+	if err == nil {
+		err = commitChanges("Regenerate project code")
+	}
+	return err
+}
+
+// generate writes all of the generated files for the project
+func generate() (err error) {
+	// without -env the last generated environment is used, so
+	// checks and regenerating don't switch back to the default
+	if genEnv != "" {
+		err = config().SelectEnvironment(genEnv)
+	} else {
+		err = config().SelectGeneratedEnvironment(root)
+	}
+
+	if err == nil {
+		err = config().Validate()
+	}
+
+	if err == nil {
+		err = config().SyncGoMod(root)
+	}
+
+	// saving brings the OpenAPI document up to date with
+	// any changes made directly to the project config
+	if err == nil {
+		err = config().SaveDef()
+	}
+
+	if err == nil {
+		err = waffle.WriteConfigSchema(filepath.Join(root, waffle.DefSchemaFile))
+	}
+
+	if err == nil {
+		err = waffle.ExecuteTemplates("generate", root, *config())
+	}
+
+	if err == nil {
+		err = config().WriteLicense(filepath.Join(root, waffle.DefLicenseFile))
+	}
+
+	if err == nil {
+		err = config().WriteGeneratedEnvironment(root)
+	}
+	return err
+}
+
+// checkGenerated reports the files that are out of date
+// with the project config
+func checkGenerated() error {
+	files, err := waffle.CheckWrites(generate)
+	if err == nil && len(files) > 0 {
+		for _, file := range files {
+			log.Logf("<fail>%s</fail> is out of date", file)
+		}
+		err = fmt.Errorf("%w, run \"waffle generate\" to update them", waffle.ErrOutOfDate)
+	}
+
+	if err == nil {
+		log.Logf("Generated files are <success>up to date</success>")
+	}
+	return err
 }
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/abates/waffle"
+)
+
+func init() {
+	hooksCmd := app.AddCommand("hooks", "manage git hooks", nil)
+	hooksCmd.AddCommand("install", "install git hooks that check generated code and the OpenAPI document", installHooks)
+}
+
+func installHooks(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", waffle.ErrUsage, args)
+	}
+
+	repo, err := waffle.OpenGit(root)
+	for i := 0; err == nil && i < len(waffle.Hooks); i++ {
+		err = repo.InstallHook(waffle.Hooks[i])
+		if err == nil {
+			log.Logf("Installed <success>%s</success> hook", waffle.Hooks[i])
+		}
+	}
+	return err
+}
@@ -2,78 +2,235 @@ package main
 
 import (
 	"errors"
-	"os"
+	"flag"
+	"fmt"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/abates/waffle"
 )
 
 var initRepo *waffle.GitRepo
 var gitRemote string
+var gitBranch string
+var tagInit bool
+
+// initFlags are the init command's flags, the wizard doesn't
+// ask for settings that were given as flags
+var initFlags *flag.FlagSet
+
+// loadedConfig is the project config before any defaults are
+// filled in, the wizard doesn't ask for settings that it has
+var loadedConfig waffle.Config
 
 func init() {
-	config().LoadDef()
+	loadedConfig = *config()
+
+	var err error
+	initRepo, err = waffle.OpenGit(root)
+	if errors.Is(err, waffle.ErrNoGitRepo) {
+		err = nil
+	}
 
-	dir, err := os.Getwd()
 	if err != nil {
-		exit("Failed to determine <fail>local directory name</fail>: %v", err.Error())
+		exit("Failed to initialize command: %v", err)
 	}
 
-	initRepo, err = waffle.OpenGit(".")
-	if err == nil {
-		// load version from git
-		if config().Module.Version == (waffle.Version{0, 0, 0}) {
-			config().Module.Version, err = initRepo.CurrentVersion()
-			if errors.Is(err, waffle.ErrNoGitVersion) {
-				err = nil
-			}
-		}
-	} else if errors.Is(err, waffle.ErrNoGitRepo) {
+	// flag defaults are taken from the loaded config so that
+	// registering the flags doesn't clobber existing values
+	cmd := app.AddCommand("init", "initialize current directory with new project tree", initCmd)
+	cmd.Flags.StringVar(&config().Name, "name", config().Name, "Project name (defaults to the origin repo or directory name)")
+	cmd.Flags.StringVar(&config().Desc, "desc", config().Desc, "Project description")
+	cmd.Flags.Var(&config().Module.Version, "version", "Current version")
+	cmd.Flags.StringVar(&config().Maintainer.Name, "maintainer", config().Maintainer.Name, "Maintainer name (defaults to git's user.name)")
+	cmd.Flags.StringVar(&config().Maintainer.Email, "email", config().Maintainer.Email, "Maintainer email (defaults to git's user.email)")
+	cmd.Flags.StringVar(&config().URL, "url", config().URL, "Project Webpage URL (defaults to the origin webpage)")
+	cmd.Flags.StringVar(&config().Org, "org", config().Org, "Organization that owns the project")
+	cmd.Flags.Var(&config().Authors, "author", "Additional author in the form \"Name <email>\" (may be repeated)")
+	cmd.Flags.StringVar(&config().License, "license", config().License, "SPDX identifier of the project license (ie MIT)")
+	cmd.Flags.StringVar(&config().Module.Path, "mod", config().Module.Path, "Go Module Path (defaults to the origin module path)")
+	cmd.Flags.Var(&config().Features, "features", "Comma separated optional features to enable (docker, hooks)")
+	cmd.Flags.StringVar(&gitRemote, "origin", "", "Git remote URL (ie git@github.com:org/repo.git)")
+	cmd.Flags.StringVar(&gitBranch, "branch", waffle.DefaultBranch(), "Initial branch of a new git repo")
+	cmd.Flags.BoolVar(&tagInit, "tag", false, "Tag the initial commit with the project version (default 0.1.0)")
+	initFlags = cmd.Flags
+}
+
+// originDefaults fills in the project name, URL and module path from
+// the git origin URL.  A missing or unrecognized origin isn't an error
+// since the fields can still be given as flags or prompted for
+func originDefaults() error {
+	err := config().DefaultsFromOrigin(initRepo)
+	if errors.Is(err, waffle.ErrInvalidGitURL) || errors.Is(err, waffle.ErrNoRepoName) {
+		log.Logf("<warn>Warning</warn>: %v", err)
+		err = nil
+	} else if errors.Is(err, waffle.ErrNoOrigin) {
 		err = nil
 	}
+	return err
+}
 
-	if err == nil {
-		config().Maintainer, err = waffle.LoadGitMaintainer()
+// gitMaintainer fills in the maintainer from the git identity if it
+// isn't set.  The identity is prompted for if it can't be found
+func gitMaintainer() {
+	maintainer, err := waffle.LoadGitMaintainer(root)
+	if err != nil {
+		log.Logf("<warn>Warning</warn>: failed to read git identity: %v", err)
 	}
 
-	if err != nil {
-		exit("Failed to initialize command: %v", err)
+	if config().Maintainer.Name == "" {
+		config().Maintainer.Name = maintainer.Name
 	}
 
-	cmd := app.AddCommand("init", "initialize current directory with new project tree", initCmd)
-	cmd.Flags.StringVar(&config().Name, "name", filepath.Base(dir), "Project name")
-	cmd.Flags.StringVar(&config().Desc, "desc", "", "Project description")
-	cmd.Flags.Var(&config().Module.Version, "version", "Current version")
-	cmd.Flags.StringVar(&config().Maintainer.Name, "maintainer", config().Maintainer.Name, "Maintainer name")
-	cmd.Flags.StringVar(&config().Maintainer.Email, "email", config().Maintainer.Email, "Maintainer email")
-	cmd.Flags.StringVar(&config().URL, "url", "", "Project Webpage URL")
-	cmd.Flags.StringVar(&config().Module.Path, "mod", config().Module.Path, "Go Module Path")
-	cmd.Flags.StringVar(&gitRemote, "origin", "", "Go Module Path")
+	if config().Maintainer.Email == "" {
+		config().Maintainer.Email = maintainer.Email
+	}
 }
 
 func initCmd(args ...string) (err error) {
 	if initRepo == nil {
-		initRepo, err = waffle.InitGit(".")
-		if err == nil {
-			if config().Module.Path == "" {
-				config().Module.Path = waffle.PromptStr("Module Path: ")
-			}
+		initRepo, err = waffle.InitGit(root, gitBranch)
+		if err != nil {
+			exit("Could not initialize <fail>empty git repo</fail>: %v", err)
+		}
 
-			if gitRemote == "" {
-				gitRemote = waffle.PromptStr("Git Remote URL: ")
+		if gitRemote == "" {
+			// the remote is optional, so it isn't an error if
+			// the user can't be asked for it
+			gitRemote, err = waffle.PromptStr("Git Remote URL: ")
+			if waffle.IsNoInput(err) {
+				err = nil
 			}
+		}
+	}
 
-			if initRepo.SetOrigin(gitRemote) != nil {
-				exit("Couldn't set git remote: %v", err)
-			}
-		} else {
-			exit("Could not initialize <fail>empty git repo</fail>: %v", err)
+	// the initial commit is made in a repo without any commits,
+	// even if it already existed, so that an init that failed
+	// part way through can be run again
+	empty := false
+	if err == nil {
+		empty, err = initRepo.IsEmpty()
+	}
+
+	if err == nil && !empty && config().Module.Version == (waffle.Version{}) {
+		// load version from git
+		config().Module.Version, err = initRepo.CurrentVersion()
+		if errors.Is(err, waffle.ErrNoGitVersion) {
+			err = nil
+		}
+	}
+
+	if err == nil && gitRemote != "" {
+		if err = initRepo.SetOrigin(gitRemote); err != nil {
+			exit("Couldn't set git remote: %v", err)
 		}
 	}
 
-	err = config().SaveDef()
 	if err == nil {
-		err = genCmd()
+		err = originDefaults()
+	}
+
+	if err == nil {
+		gitMaintainer()
+	}
+
+	if err == nil && config().Name == "" {
+		var dir string
+		if dir, err = filepath.Abs(root); err == nil {
+			config().Name = filepath.Base(dir)
+		}
+	}
+
+	if err == nil {
+		err = initWizard(empty)
+	}
+
+	if config().Schema == "" {
+		// lets editors validate the config file
+		config().Schema = "./" + waffle.DefSchemaFile
+	}
+
+	if len(config().Environments) == 0 {
+		config().Environments = waffle.DefaultEnvironments()
+	}
+
+	if empty && tagInit && config().Module.Version == (waffle.Version{}) {
+		config().Module.Version = waffle.Version{Minor: 1}
+	}
+
+	if config().Year == 0 {
+		// the copyright year is fixed when the project is created, an
+		// existing repo uses the year of its first commit
+		config().Year = config().CopyrightYear()
+		if config().Year == 0 {
+			config().Year = time.Now().Year()
+		}
+	}
+
+	if err == nil {
+		err = config().SaveDef()
+	}
+
+	if err == nil {
+		err = generate()
+	}
+
+	if err == nil {
+		err = waffle.ExecuteTemplates("init", root, *config())
+	}
+
+	if err == nil && empty {
+		err = commitInit()
+	}
+
+	if err == nil && config().HasFeature("hooks") {
+		err = installHooks()
+	}
+	return err
+}
+
+// commitInit creates the first commit in a new repo, and tags
+// it with the project version if -tag was given
+func commitInit() error {
+	maintainer := config().Maintainer
+	if maintainer.Name == "" || maintainer.Email == "" {
+		log.Logf("<warn>Warning</warn>: skipping the initial commit, set the maintainer name and email or git's user.name and user.email first")
+		return nil
+	}
+
+	_, err := initRepo.InitialCommit("Initial commit", maintainer)
+	branch := ""
+	if err == nil {
+		branch, err = initRepo.Branch()
+	}
+
+	if err == nil {
+		log.Logf("Committed <success>Initial commit</success> on <hl>%s</hl>", branch)
+		if tagInit {
+			name := initRepo.TagName(config().Module.Version)
+			err = initRepo.TagAs(name, "Release "+name, maintainer)
+			if err == nil {
+				log.Logf("Tagged <success>%s</success>", name)
+			}
+		}
 	}
 	return err
 }
+
+func checkRequired(str string) error {
+	if strings.TrimSpace(str) == "" {
+		return waffle.ErrRequired
+	}
+	return nil
+}
+
+// prompt asks for a value that can also be given with the named flag.  If
+// the user can't be prompted the error says to use the flag instead
+func prompt(label, flag, def string, validator waffle.Validator) (string, error) {
+	value, err := waffle.PromptDefault(label, def, validator)
+	if waffle.IsNoInput(err) {
+		err = fmt.Errorf("%w, use -%s to set it", err, flag)
+	}
+	return value, err
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/abates/waffle"
 )
@@ -15,6 +17,74 @@ var log = waffle.Logger
 
 var c *waffle.Config
 
+var projectDir string
+
+// flagErr is set if the global flags couldn't be parsed
+var flagErr error
+
+// root is the project root directory and args are the arguments
+// that follow the global flags.  These are determined before anything
+// else since the config is loaded while the commands are registered
+var root, args = parseGlobalFlags()
+
+func parseGlobalFlags() (string, []string) {
+	app.Name = filepath.Base(os.Args[0])
+	usage := "Run as if waffle was started in `dir` instead of the current directory"
+	app.Flags.StringVar(&projectDir, "C", "", usage)
+	app.Flags.StringVar(&projectDir, "project-dir", "", usage)
+	app.Flags.BoolVar(&waffle.NoInput, "no-input", false, "Fail instead of prompting for missing values")
+
+	// usage is printed by main since the commands
+	// haven't been registered yet
+	app.Flags.Init("", flag.ContinueOnError)
+	app.Flags.Usage = func() {}
+	if flagErr = app.Flags.Parse(os.Args[1:]); flagErr != nil {
+		return ".", nil
+	}
+
+	dir := projectDir
+	if dir == "" {
+		dir = "."
+	}
+
+	// init creates a project in dir, searching the parents would
+	// find an enclosing project and overwrite it
+	if args := app.Flags.Args(); len(args) > 0 && args[0] == "init" {
+		return dir, args
+	}
+
+	root, err := waffle.FindProjectRoot(dir)
+	if errors.Is(err, waffle.ErrNoProject) {
+		// this is a new project
+		root, err = dir, nil
+	} else if err == nil {
+		// relative paths are easier to read in messages
+		var wd string
+		if wd, err = os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, root); err == nil {
+				root = rel
+			}
+		}
+	}
+
+	if err != nil {
+		exit("Failed to find <fail>project root</fail>: %v", err)
+	}
+	return root, app.Flags.Args()
+}
+
+// history is opened before anything else so that an interrupted
+// command is rolled back before the config is loaded
+var history = openHistory()
+
+func openHistory() *waffle.History {
+	history, err := waffle.OpenHistory(root, strings.Join(args, " "))
+	if err != nil {
+		exit("Failed to open <fail>history</fail>: %v", err)
+	}
+	return history
+}
+
 func exit(format string, v ...interface{}) {
 	log.Logf(format, v...)
 	os.Exit(1)
@@ -23,8 +93,13 @@ func exit(format string, v ...interface{}) {
 func config() *waffle.Config {
 	if c == nil {
 		c = &waffle.Config{}
-		err := c.LoadDef()
-		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		err := c.LoadDir(root)
+		var apiErr waffle.APIValidationError
+		if errors.As(err, &apiErr) {
+			// keep going so the document can be fixed or
+			// checked with "api validate"
+			log.Logf("<warn>Warning</warn>: %v", err)
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
 			exit("Failed to load <fail>default config</fail>: %v", err.Error())
 		}
 	}
@@ -32,8 +107,19 @@ func config() *waffle.Config {
 }
 
 func main() {
-	app.Name = filepath.Base(os.Args[0])
-	err := app.Run(os.Args[1:]...)
+	if flagErr != nil {
+		app.Usage()
+		if flagErr == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+
+	err := app.Run(args...)
+	if err1 := history.Close(); err1 != nil {
+		log.Logf("Failed to save <fail>history</fail>: %v", err1)
+	}
+
 	if err != nil {
 		os.Exit(1)
 	}
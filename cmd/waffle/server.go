@@ -1,6 +1,12 @@
 package main
 
-import "github.com/abates/waffle"
+import (
+	"fmt"
+
+	"github.com/abates/waffle"
+)
+
+var ctrlDesc string
 
 func init() {
 	serverCmd := app.AddCommand("server", "manage api server controllers and endpoints", nil)
@@ -8,24 +14,39 @@ func init() {
 	addCmd := serverCmd.AddCommand("add", "add controllers, endpoints and security", nil)
 	ctrlCmd := addCmd.AddCommand("controller", "add a controller to the server", addController)
 	ctrlCmd.UsageStr = "<name> <path>"
+	ctrlCmd.Flags.StringVar(&ctrlDesc, "desc", "", "Controller description")
+	addWorktreeFlags(ctrlCmd, true)
 	addCmd.AddCommand("endpoint", "add a controller to the server", addEndpoint)
 
 	removeCmd := serverCmd.AddCommand("remove", "remove controllers, endpoints and security", nil)
-	removeCmd.AddCommand("controller", "remove a controller from the server", rmController)
+	rmCtrlCmd := removeCmd.AddCommand("controller", "remove a controller from the server", rmController)
+	rmCtrlCmd.UsageStr = "<name>"
+	addWorktreeFlags(rmCtrlCmd, true)
 	removeCmd.AddCommand("endpoint", "remove a controller from the server", rmEndpoint)
 
 	serverCmd.AddCommand("generate", "generate code specified in openapi.json", generateServer)
 }
 
 func addController(args ...string) error {
-	if len(args) < 2 {
+	if len(args) != 2 {
 		return waffle.ErrUsage
 	}
 
-	config().AddController(args[0])
-	err := config().SaveDef()
+	err := checkWorktree()
+	if err == nil {
+		err = config().AddController(args[0], args[1], ctrlDesc)
+	}
+
+	if err == nil {
+		err = config().SaveDef()
+	}
+
 	if err == nil {
-		err = genCmd()
+		err = generate()
+	}
+
+	if err == nil {
+		err = commitChanges(fmt.Sprintf("Add %s controller", args[0]))
 	}
 	return err
 }
@@ -35,7 +56,33 @@ func addEndpoint(args ...string) error {
 }
 
 func rmController(args ...string) error {
-	return nil
+	if len(args) != 1 {
+		return waffle.ErrUsage
+	}
+
+	err := checkWorktree()
+	ctrl, found := config().Controller(args[0])
+	if err == nil {
+		err = config().RemoveController(args[0])
+	}
+
+	if err == nil {
+		err = config().SaveDef()
+	}
+
+	if err == nil && found {
+		// the generated file would otherwise be left behind
+		err = waffle.RemoveGenerated(root, "api/"+ctrl.FileName()+".go")
+	}
+
+	if err == nil {
+		err = generate()
+	}
+
+	if err == nil {
+		err = commitChanges(fmt.Sprintf("Remove %s controller", args[0]))
+	}
+	return err
 }
 
 func rmEndpoint(args ...string) error {
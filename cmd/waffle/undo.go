@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abates/waffle"
+)
+
+func init() {
+	app.AddCommand("undo", "revert the files changed by the last command", undoCmd)
+}
+
+func undoCmd(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", waffle.ErrUsage, args)
+	}
+
+	command, err := history.Undo()
+	if err == nil {
+		log.Logf("Undid <hl>%s</hl>", command)
+		if strings.HasPrefix(command, "version bump") {
+			log.Logf("<warn>Warning</warn>: the release commit and tag were not removed from git")
+		}
+	}
+	return err
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/abates/waffle"
+)
+
+var dryRun bool
+var preID string
+var signTag bool
+var signKey string
+var verifyTags bool
+var keyring string
+
+var bumpCmd *waffle.Command
+
+func init() {
+	versionCmd := app.AddCommand("version", "show and bump the project version", nil)
+	versionCmd.AddCommand("show", "show the project and git versions", showVersion)
+
+	listCmd := versionCmd.AddCommand("list", "list the release tags", listVersions)
+	listCmd.Flags.BoolVar(&verifyTags, "verify", false, "Verify the OpenPGP or SSH signature of each release tag")
+	listCmd.Flags.StringVar(&keyring, "keyring", "", "Armored OpenPGP keys or SSH allowed signers to verify with (default is git's gpg.ssh.allowedSignersFile for SSH, otherwise the signing key)")
+
+	bumpCmd = versionCmd.AddCommand("bump", "bump the project version, then commit and tag the release", bumpVersion)
+	bumpCmd.UsageStr = "major|minor|patch|prerelease"
+	bumpCmd.Flags.BoolVar(&dryRun, "dry-run", false, "Show the next version without changing anything")
+	bumpCmd.Flags.StringVar(&preID, "preid", "rc", "Pre-release identifier used for prerelease bumps")
+	bumpCmd.Flags.BoolVar(&signTag, "sign", false, "Sign the release tag with an OpenPGP or SSH key (default is git's tag.gpgSign)")
+	bumpCmd.Flags.StringVar(&signKey, "sign-key", "", "Armored OpenPGP or SSH private key `file` to sign with, GPG key IDs can't be used (default is git's user.signingKey)")
+	addWorktreeFlags(bumpCmd, false)
+}
+
+func showVersion(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", waffle.ErrUsage, args)
+	}
+
+	log.Logf("Project version: <hl>%s</hl>", config().Module.Version.String())
+	repo, err := waffle.OpenGit(root)
+	if err == nil {
+		var d waffle.Description
+		d, err = repo.Describe()
+		if err == nil && d.Release != nil {
+			log.Logf("    Git version: <hl>%s</hl>", d.Release.Version.String())
+		} else if err == nil {
+			log.Logf("    Git version: <warn>none</warn>")
+		}
+
+		if err == nil {
+			log.Logf("       Describe: <hl>%s</hl>", d.String())
+			version := d.Version()
+			log.Logf(" Pseudo-version: <hl>%s</hl>", version.Tag())
+		} else if errors.Is(err, waffle.ErrNoGitVersion) {
+			log.Logf("    Git version: <warn>none</warn>")
+			err = nil
+		}
+	} else if errors.Is(err, waffle.ErrNoGitRepo) {
+		err = nil
+	}
+	return err
+}
+
+func bumpVersion(args ...string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expecting major, minor, patch or prerelease", waffle.ErrUsage)
+	}
+
+	repo, err := waffle.OpenGit(root)
+	if err != nil {
+		return err
+	}
+
+	// a dry run doesn't change anything, so it can't
+	// be mixed up with uncommitted changes
+	force = force || dryRun
+	if err = checkWorktree(); err != nil {
+		return err
+	}
+
+	current, err := repo.CurrentVersion()
+	if errors.Is(err, waffle.ErrNoGitVersion) {
+		current, err = config().Module.Version, nil
+	}
+
+	var next waffle.Version
+	if err == nil {
+		if args[0] == "prerelease" {
+			next, err = current.BumpPreRelease(preID)
+		} else {
+			next, err = current.Bump(args[0])
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log.Logf("Bumping version <hl>%s</hl> -> <hl>%s</hl>", current.String(), next.String())
+	if dryRun {
+		return nil
+	}
+
+	if err = setSigningKey(repo); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("Release %s", repo.TagName(next))
+	config().Module.Version = next
+	err = config().SaveDef()
+	if err == nil {
+		_, err = repo.Commit(msg, history.Files()...)
+		if errors.Is(err, waffle.ErrNoChanges) {
+			// project.json already has the version, so
+			// the release only needs to be tagged
+			log.Logf("Nothing to commit, %s already has version <hl>%s</hl>", waffle.DefConfigFile, next.String())
+			err = nil
+		}
+	}
+
+	if err == nil {
+		err = repo.Tag(repo.TagName(next), msg)
+	}
+
+	if err == nil {
+		log.Logf("Tagged <success>%s</success>", repo.TagName(next))
+	}
+	return err
+}
+
+// setSigningKey loads the key to sign release tags with if -sign or
+// -sign-key were given, or if git's tag.gpgSign is set
+func setSigningKey(repo *waffle.GitRepo) error {
+	sc, err := repo.SigningConfig()
+	if err != nil {
+		return err
+	}
+
+	sign := sc.Sign || signKey != ""
+	bumpCmd.Flags.Visit(func(f *flag.Flag) {
+		if f.Name == "sign" {
+			sign = signTag
+		}
+	})
+
+	if !sign {
+		return nil
+	}
+
+	if sc.Format != "" && sc.Format != "openpgp" && sc.Format != "ssh" {
+		return fmt.Errorf("%w (gpg.format is %s)", waffle.ErrSigningFormat, sc.Format)
+	}
+
+	filename := signKey
+	if filename == "" {
+		if filename, err = sc.SigningKeyFile(); err != nil {
+			return err
+		}
+	}
+
+	key, err := waffle.LoadSigningKey(filename)
+	if err == nil {
+		repo.SetSigningKey(key)
+	}
+	return err
+}
+
+// keyringFile returns the file with the keys that release tags are
+// verified with.  This is -keyring or the keyring from git config
+func keyringFile(repo *waffle.GitRepo) (string, error) {
+	if keyring != "" {
+		return keyring, nil
+	}
+
+	sc, err := repo.SigningConfig()
+	filename := ""
+	if err == nil {
+		filename, err = sc.KeyringFile()
+		if errors.Is(err, waffle.ErrNoSigningKey) {
+			err = fmt.Errorf("%w, or use -keyring to verify with a key file", err)
+		}
+	}
+	return filename, err
+}
+
+func listVersions(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", waffle.ErrUsage, args)
+	}
+
+	repo, err := waffle.OpenGit(root)
+	var releases []waffle.Release
+	if err == nil {
+		releases, err = repo.Releases()
+	}
+
+	var keys string
+	if err == nil && verifyTags {
+		var filename string
+		if filename, err = keyringFile(repo); err == nil {
+			keys, err = waffle.ReadKeyring(filename)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, release := range releases {
+		format := "<hl>%-12s</hl> %s %s"
+		v := []interface{}{release.Tag, release.Commit.Hash.String()[:7], release.Commit.Committer.When.Format("2006-01-02")}
+		if verifyTags {
+			signer, err := repo.VerifyRelease(release, keys)
+			if err == nil {
+				format += " <success>signed</success> by %s"
+				v = append(v, signer)
+			} else if errors.Is(err, waffle.ErrUnsigned) {
+				format += " <warn>unsigned</warn>"
+				failed++
+			} else {
+				format += " <fail>bad signature</fail>: %v"
+				v = append(v, err)
+				failed++
+			}
+		}
+		log.Logf(format, v...)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d release tag(s) failed verification", failed)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/abates/waffle"
+)
+
+// initSetting is a config field that the init wizard asks for
+type initSetting struct {
+	flag      string
+	label     string
+	value     *string
+	loaded    string
+	required  bool
+	validator waffle.Validator
+}
+
+// initWizard asks for each init setting that wasn't given as a flag
+// and wasn't already in the project config.  The current values, which
+// are defaulted from git and the directory name, are offered as the
+// answers.  If the user can't be prompted only missing required settings
+// are an error.  If the repo is empty the user is also asked whether to
+// tag the initial commit
+func initWizard(empty bool) (err error) {
+	given := map[string]bool{}
+	initFlags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	interactive := waffle.CanPrompt() == nil
+
+	c := config()
+	settings := []initSetting{
+		{"name", "Project Name", &c.Name, loadedConfig.Name, true, waffle.CheckName},
+		{"desc", "Description", &c.Desc, loadedConfig.Desc, false, nil},
+		{"mod", "Module Path", &c.Module.Path, loadedConfig.Module.Path, true, waffle.CheckModulePath},
+		{"url", "Project Webpage URL", &c.URL, loadedConfig.URL, false, waffle.CheckURL},
+		{"org", "Organization", &c.Org, loadedConfig.Org, false, nil},
+		{"maintainer", "Maintainer Name", &c.Maintainer.Name, loadedConfig.Maintainer.Name, true, nil},
+		{"email", "Maintainer Email", &c.Maintainer.Email, loadedConfig.Maintainer.Email, true, waffle.CheckEmail},
+	}
+
+	for _, s := range settings {
+		if given[s.flag] || s.loaded != "" || !interactive && !(s.required && *s.value == "") {
+			continue
+		}
+
+		validator := s.validator
+		if s.required {
+			validator = func(str string) error {
+				err := checkRequired(str)
+				if err == nil && s.validator != nil {
+					err = s.validator(str)
+				}
+				return err
+			}
+		}
+
+		if *s.value, err = prompt(s.label+": ", s.flag, *s.value, validator); err != nil {
+			return err
+		}
+	}
+
+	if !interactive {
+		return nil
+	}
+
+	if !given["license"] && loadedConfig.License == "" {
+		err = selectLicense()
+	}
+
+	if err == nil && !given["features"] && len(loadedConfig.Features) == 0 {
+		err = selectFeatures()
+	}
+
+	if err == nil && empty && !given["tag"] {
+		tagInit, err = waffle.PromptConfirm("Tag the initial commit as a release", tagInit)
+	}
+	return err
+}
+
+func selectLicense() error {
+	items := []string{"None"}
+	def := 0
+	for i, license := range waffle.Licenses {
+		items = append(items, fmt.Sprintf("%s (%s)", license.Name, license.ID))
+		if license.ID == config().License {
+			def = i + 1
+		}
+	}
+
+	i, err := waffle.PromptSelect("License", items, def)
+	if err == nil && i == 0 {
+		config().License = ""
+	} else if err == nil {
+		config().License = waffle.Licenses[i-1].ID
+	}
+	return err
+}
+
+func selectFeatures() error {
+	items := []string{}
+	selected := []bool{}
+	for _, feature := range waffle.AllFeatures {
+		items = append(items, fmt.Sprintf("%s: %s", feature.Name, feature.Desc))
+		selected = append(selected, config().HasFeature(feature.Name))
+	}
+
+	selected, err := waffle.PromptMultiSelect("Features", items, selected)
+	if err == nil {
+		config().Features = nil
+		for i, feature := range waffle.AllFeatures {
+			if selected[i] {
+				config().Features = append(config().Features, feature.Name)
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/abates/waffle"
+)
+
+var force bool
+var autoCommit bool
+
+// addWorktreeFlags adds the flags that control the uncommitted changes
+// check to cmd.  If commit is true then the -commit flag is added as well
+func addWorktreeFlags(cmd *waffle.Command, commit bool) {
+	cmd.Flags.BoolVar(&force, "force", false, "Run even if the git worktree has uncommitted changes")
+	if commit {
+		cmd.Flags.BoolVar(&autoCommit, "commit", false, "Commit the files that were changed")
+	}
+}
+
+// checkWorktree refuses to continue if the project's git worktree has
+// uncommitted changes, since they would be mixed in with the changes
+// waffle makes.  Projects that aren't in a git repo are not checked
+func checkWorktree() error {
+	repo, err := waffle.OpenGit(root)
+	if errors.Is(err, waffle.ErrNoGitRepo) {
+		if autoCommit {
+			return fmt.Errorf("Can't commit changes: %w", err)
+		}
+		return nil
+	}
+
+	clean := false
+	if err == nil {
+		clean, err = repo.IsClean()
+	}
+
+	if err == nil && !clean {
+		if !force {
+			return fmt.Errorf("%w, commit or stash them first or use -force", waffle.ErrDirtyWorktree)
+		}
+		log.Logf("<warn>Warning</warn>: %v", waffle.ErrDirtyWorktree)
+	}
+
+	if err == nil && autoCommit {
+		// only the files waffle writes should end up in the commit
+		var staged bool
+		if staged, err = repo.HasStagedChanges(); err == nil && staged {
+			err = waffle.ErrStagedChanges
+		}
+	}
+	return err
+}
+
+// commitChanges commits every file the command wrote if -commit was given
+func commitChanges(msg string) error {
+	if !autoCommit {
+		return nil
+	}
+
+	repo, err := waffle.OpenGit(root)
+	if err == nil {
+		_, err = repo.Commit(msg, history.Files()...)
+	}
+
+	if errors.Is(err, waffle.ErrNoChanges) {
+		log.Logf("Nothing to commit, no files were changed")
+		err = nil
+	} else if err == nil {
+		log.Logf("Committed <success>%s</success>", msg)
+	}
+	return err
+}
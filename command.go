@@ -55,6 +55,11 @@ func NewCommand() *Command {
 
 	cmd.Usage = Usage(cmd)
 	cmd.Run = cmd.Runner
+
+	// global flags must be given before the sub-command
+	cmd.Flags = flag.NewFlagSet("", flag.ExitOnError)
+	cmd.Flags.SetOutput(cmd.output)
+	cmd.Flags.Usage = cmd.Usage
 	return cmd
 }
 
@@ -6,83 +6,13 @@ import (
 	"fmt"
 	"io/fs"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/getkin/kin-openapi/openapi3"
 )
 
-// VersionList is a list that is sortable
-// by semantic naming
-type VersionList []Version
-
-// Len of the version list.  This satisfies one of the
-// requirements of the sort.Interface interface
-func (vl VersionList) Len() int { return len(vl) }
-
-// Less determines if version[i] is less than version[j]
-// This satisfies one of the requirements of the sort.Interface
-// interface
-func (vl VersionList) Less(i, j int) bool {
-	if vl[i].Major > vl[j].Major {
-		return false
-	}
-
-	if vl[i].Minor > vl[j].Minor {
-		return false
-	}
-
-	if vl[i].Patch > vl[j].Patch {
-		return false
-	}
-	return true
-}
-
-// Swap will swap the versions at indices i and j.  This
-// satisfies one of the requirements of the sort.Interface interface
-func (vl VersionList) Swap(i, j int) { vl[i], vl[j] = vl[j], vl[i] }
-
-// Version is a struct representation of a semantic version
-type Version struct {
-	// Major release number
-	Major int
-	// Minor release number
-	Minor int
-	// Patch release number
-	Patch int
-}
-
-// String will convert the Version so a string in the form
-// Major.Minor.Patch ie 1.0.0
-func (v *Version) String() string {
-	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
-}
-
-// MarshalJSON will convert the Version to a JSON string
-// such as "1.0.0"
-func (v *Version) MarshalJSON() ([]byte, error) {
-	return json.Marshal(v.String())
-}
-
-// Set will parse the string and populate
-// the appropriate Version fields
-func (v *Version) Set(str string) error {
-	_, err := fmt.Sscanf(str, "%d.%d.%d", &v.Major, &v.Minor, &v.Patch)
-	return err
-}
-
-// UnmarshalJSON will parse a JSON string and populate
-// the fields Major, Minor and Parse.  The only valid
-// input is "x.y.z" where x is the major number (integer)
-// y is the minor number (integer) and z is the patch
-// number (also integer).
-func (v *Version) UnmarshalJSON(input []byte) error {
-	str := ""
-	err := json.Unmarshal(input, &str)
-	if err == nil {
-		err = v.Set(str)
-	}
-	return err
-}
-
 // Maintainer contains information about the maintainer of a project
 type Maintainer struct {
 	// Name is some identifying information about the individual
@@ -93,12 +23,28 @@ type Maintainer struct {
 	Org string `json:"org"`
 }
 
+// String returns the maintainer in the form "Name <email>"
+func (m Maintainer) String() string {
+	str := m.Name
+	if m.Email != "" {
+		str = strings.TrimSpace(fmt.Sprintf("%s <%s>", str, m.Email))
+	}
+
+	if m.Org != "" {
+		str = fmt.Sprintf("%s (%s)", str, m.Org)
+	}
+	return str
+}
+
 type Module struct {
 	// Path is the projects go module path
 	Path string `json:"path"`
 
 	// Version is the semantic version for the project
 	Version Version `json:"version"`
+
+	// GoVersion is the go version from the go directive in go.mod
+	GoVersion string `json:"go,omitempty"`
 }
 
 const (
@@ -109,11 +55,17 @@ const (
 
 	// OpenAPIVersion is the version of JSON that is written to the api config file
 	OpenAPIVersion = "3.1.0"
+
+	ErrNoProject = WaffleError("no project found")
 )
 
 // Config represents all the information about a
 // waffle project
 type Config struct {
+	// Schema is the location of the JSON Schema for the config
+	// file so that editors can validate it
+	Schema string `json:"$schema,omitempty"`
+
 	// Name is the title of the project
 	Name string `json:"name"`
 
@@ -124,6 +76,20 @@ type Config struct {
 	// is responsible for the project
 	Maintainer Maintainer `json:"maintainer"`
 
+	// Authors lists anyone else who has contributed to the project
+	Authors Maintainers `json:"authors,omitempty"`
+
+	// Org is the organization that owns the project.  If set, it is
+	// used as the copyright holder in the license
+	Org string `json:"org"`
+
+	// License is the SPDX identifier for the project license (ie MIT)
+	License string `json:"license"`
+
+	// Year is the copyright year in the license.  It is set when the
+	// project is created so that generated files don't change each year
+	Year int `json:"year,omitempty"`
+
 	// URL is a link to the project webpage
 	URL string `json:"url"`
 
@@ -131,77 +97,231 @@ type Config struct {
 	// and repository
 	Module Module `json:"mod"`
 
-	apiConfig *openapi3.T // not exported so it's easer to marshal the config to json
-}
+	// Controllers are the groups of endpoints served by the API
+	Controllers []Controller `json:"controllers,omitempty"`
+
+	// Environments are the places the API is deployed, each with
+	// its own server settings
+	Environments []Environment `json:"environments,omitempty"`
+
+	// Features are the optional parts of the project that are enabled
+	Features Features `json:"features,omitempty"`
+
+	// environment is the name of the environment code is generated for
+	environment string
 
-func (c *Config) AddController(name string) error {
-	return nil
+	// dir is the project root
+	dir string
+
+	apiConfig *openapi3.T // not exported so it's easer to marshal the config to json
 }
 
 func (c *Config) APIConfig() *openapi3.T {
 	return c.apiConfig
 }
 
-func save(fileType, filename string, data interface{}) error {
-	content, err := json.MarshalIndent(data, "", "  ")
+// updateAPIInfo copies the project information into the
+// info section of the OpenAPI document
+func (c *Config) updateAPIInfo() {
+	// the document is converted to OpenAPIVersion when it is saved
+	c.apiConfig.OpenAPI = OpenAPI30Version
+	if c.apiConfig.Info == nil {
+		c.apiConfig.Info = &openapi3.Info{}
+	}
+	c.apiConfig.Info.Title = c.Name
+	c.apiConfig.Info.Description = c.Desc
+	c.apiConfig.Info.Contact = &openapi3.Contact{
+		Name:  c.Maintainer.Name,
+		URL:   c.URL,
+		Email: c.Maintainer.Email,
+	}
+	c.apiConfig.Info.Version = c.Module.Version.String()
+	if c.apiConfig.Paths == nil {
+		c.apiConfig.Paths = openapi3.Paths{}
+	}
+
+	c.apiConfig.Info.License = nil
+	if c.License != "" {
+		license, err := LookupLicense(c.License)
+		if err == nil {
+			c.apiConfig.Info.License = &openapi3.License{Name: license.Name, URL: license.URL()}
+		}
+	}
+
+	if c.apiConfig.Info.Extensions == nil {
+		c.apiConfig.Info.Extensions = make(map[string]interface{})
+	}
+	delete(c.apiConfig.Info.Extensions, "x-organization")
+	delete(c.apiConfig.Info.Extensions, "x-authors")
+
+	if c.Org != "" {
+		c.apiConfig.Info.Extensions["x-organization"] = c.Org
+	}
+
+	if len(c.Authors) > 0 {
+		authors := []string{}
+		for _, author := range c.Authors {
+			authors = append(authors, author.String())
+		}
+		c.apiConfig.Info.Extensions["x-authors"] = authors
+	}
+}
+
+// apiContent converts the OpenAPI document to OpenAPIVersion and
+// validates it.  The content is what will be written to apiFile
+func (c *Config) apiContent(apiFile string) ([]byte, error) {
+	content, err := json.MarshalIndent(c.apiConfig, "", "  ")
 	if err == nil {
-		err = ioutil.WriteFile(filename, content, 0644)
-		if err != nil {
-			err = fmt.Errorf("Failed to write %q: %w", filename, err)
+		var warnings []string
+		content, warnings, err = UpgradeAPI(content)
+		for _, warning := range warnings {
+			Logger.Logf("<warn>Warning</warn>: %s: %s", apiFile, warning)
 		}
+	}
+
+	if err == nil {
+		err = ValidateAPI(c.apiConfig, content, apiFile)
 	} else {
-		err = fmt.Errorf("Failed to marshal %s: %w", fileType, err)
+		err = fmt.Errorf("Failed to marshal api config: %w", err)
 	}
-	return err
+	return content, err
 }
 
+// Save writes the project config and OpenAPI document.  Both
+// are validated before either file is written and then both
+// are replaced together so that they are never out of sync
 func (c *Config) Save(projectFile, apiFile string) error {
-	err := save("config", projectFile, c)
+	var content, apiContent []byte
+	err := c.validate(filepath.Dir(projectFile))
 	if err == nil {
-		c.apiConfig.OpenAPI = OpenAPIVersion
-		if c.apiConfig.Info == nil {
-			c.apiConfig.Info = &openapi3.Info{}
-		}
-		c.apiConfig.Info.Title = c.Name
-		c.apiConfig.Info.Description = c.Desc
-		c.apiConfig.Info.Contact = &openapi3.Contact{
-			Name:  c.Maintainer.Name,
-			URL:   c.URL,
-			Email: c.Maintainer.Email,
+		c.updateAPIInfo()
+		c.updateAPITags()
+		c.updateAPIServers()
+		apiContent, err = c.apiContent(apiFile)
+	}
+
+	if err == nil {
+		content, err = json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			err = fmt.Errorf("Failed to marshal config: %w", err)
 		}
-		c.apiConfig.Info.Version = c.Module.Version.String()
+	}
 
-		err = save("api config", apiFile, c.apiConfig)
+	if err == nil {
+		err = writeFiles(fileContent{projectFile, content}, fileContent{apiFile, apiContent})
 	}
 	return err
 }
 
+// ExportAPI writes a copy of the OpenAPI document converted to the
+// given version ("3.0" or "3.1").  Converting to 3.0 drops anything
+// that only 3.1 supports and a warning is returned for each of those
+func (c *Config) ExportAPI(filename, version string) (warnings []string, err error) {
+	c.updateAPIInfo()
+	c.updateAPITags()
+	c.updateAPIServers()
+	content, err := json.MarshalIndent(c.apiConfig, "", "  ")
+	if err == nil {
+		switch version {
+		case "3.0":
+			content, warnings, err = DowngradeAPI(content, true)
+		case "3.1":
+			content, warnings, err = UpgradeAPI(content)
+		default:
+			err = fmt.Errorf("%w %q, expected 3.0 or 3.1", ErrUnsupportedAPIVersion, version)
+		}
+	}
+
+	if err == nil {
+		err = WriteFile(filename, content)
+	}
+	return warnings, err
+}
+
+// FindProjectRoot searches dir, and then each of its parents, for
+// the project config file.  The search stops at the root of the git
+// repo so that a project is never found outside of its repo
+func FindProjectRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	for err == nil {
+		if _, err = os.Stat(filepath.Join(dir, DefConfigFile)); err == nil {
+			return dir, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+
+		parent := filepath.Dir(dir)
+		if _, err = os.Stat(filepath.Join(dir, ".git")); err == nil || parent == dir {
+			err = fmt.Errorf("%w in %s or any parent directory", ErrNoProject, dir)
+		} else if errors.Is(err, fs.ErrNotExist) {
+			dir, err = parent, nil
+		}
+	}
+	return "", err
+}
+
+// Dir is the project root directory that the config
+// was loaded from
+func (c *Config) Dir() string {
+	if c.dir == "" {
+		return "."
+	}
+	return c.dir
+}
+
+// SaveDef saves the config to the default files in the project root
 func (c *Config) SaveDef() error {
-	return c.Save(DefConfigFile, DefAPIFile)
+	return c.Save(filepath.Join(c.Dir(), DefConfigFile), filepath.Join(c.Dir(), DefAPIFile))
 }
 
+// LoadDef loads the config from the default files in the current directory
 func (c *Config) LoadDef() error {
-	return c.Load(DefConfigFile, DefAPIFile)
+	return c.LoadDir(".")
+}
+
+// LoadDir loads the config from the default files in the project
+// root dir.  The config is saved back to the same directory
+func (c *Config) LoadDir(dir string) error {
+	c.dir = dir
+	return c.Load(filepath.Join(dir, DefConfigFile), filepath.Join(dir, DefAPIFile))
 }
 
 func (c *Config) Load(projectFile, apiFile string) error {
 	content, err := ioutil.ReadFile(projectFile)
 	if err == nil {
+		// the schema finds problems such as misspelled fields that
+		// unmarshaling would ignore.  These are only warnings so that
+		// the config can still be loaded and fixed with "config set"
+		schemaErr := validateConfigSchema(content)
 		err = json.Unmarshal(content, c)
+		if err != nil && schemaErr != nil {
+			// the schema errors say which fields are wrong
+			err = schemaErr
+		} else if schemaErr != nil {
+			Logger.Logf("<warn>Warning</warn>: %s: %v", projectFile, schemaErr)
+		}
 	} else {
 		err = fmt.Errorf("Failed to load %q: %w", projectFile, err)
 	}
 
 	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		c.loadGoMod(filepath.Dir(projectFile))
+
+		var apiContent []byte
 		var err2 error
-		c.apiConfig, err2 = openapi3.NewLoader().LoadFromFile(apiFile)
-		if err != nil {
-			if errors.Is(err2, fs.ErrNotExist) {
-				c.apiConfig = &openapi3.T{}
-				err2 = nil
-			} else {
-				err2 = fmt.Errorf("Failed to load %q: %w", apiFile, err2)
-			}
+		c.apiConfig, apiContent, err2 = loadAPI(apiFile)
+		if err2 == nil {
+			// the document is still loaded so that the caller
+			// can decide whether the problems are fatal
+			err2 = ValidateAPI(c.apiConfig, apiContent, apiFile)
+		} else if errors.Is(err2, fs.ErrNotExist) {
+			c.apiConfig = &openapi3.T{}
+			err2 = nil
+		} else {
+			// don't wrap the error, a document that can't be
+			// parsed can't be used at all
+			c.apiConfig = &openapi3.T{}
+			err2 = fmt.Errorf("Failed to load %q: %v", apiFile, err2)
 		}
 
 		if err == nil && err2 != nil {
@@ -0,0 +1,168 @@
+package waffle
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const (
+	ErrControllerExists   = WaffleError("controller already exists")
+	ErrControllerNotFound = WaffleError("controller not found")
+	ErrControllerOverlap  = WaffleError("controller path overlaps an existing controller")
+	ErrInvalidPath        = WaffleError("invalid controller path")
+
+	// pathPrefixExt is the OpenAPI tag extension that records
+	// the base path of a controller
+	pathPrefixExt = "x-path-prefix"
+)
+
+// Controller is a named group of endpoints that share a base path.  Each
+// controller is recorded as a tag in the OpenAPI document and is generated
+// into its own source file
+type Controller struct {
+	// Name is the name of the controller and the OpenAPI tag
+	Name string `json:"name"`
+
+	// Path is the base path that all of the controller's endpoints
+	// are served under
+	Path string `json:"path"`
+
+	// Desc is a short description of the controller
+	Desc string `json:"desc"`
+}
+
+// TypeName is the name of the generated Go type for the controller.  The
+// name is converted to lower camel case (ie user-accounts becomes
+// userAccountsController)
+func (c Controller) TypeName() string {
+	builder := &strings.Builder{}
+	upper := false
+	for i, r := range c.Name {
+		switch {
+		case r == '-' || r == '_' || r == '.':
+			upper = true
+		case i == 0:
+			builder.WriteRune(unicode.ToLower(r))
+		case upper:
+			builder.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	builder.WriteString("Controller")
+	return builder.String()
+}
+
+// FileName is the base name (without extension) of the
+// generated source file for the controller
+func (c Controller) FileName() string {
+	return strings.ToLower(strings.NewReplacer("-", "_", ".", "_").Replace(c.Name)) + "_controller"
+}
+
+// collides returns true if the generated code for the controllers
+// would use the same file or type name (ie "a.b" and "a_b")
+func (c Controller) collides(other Controller) bool {
+	return c.FileName() == other.FileName() || c.TypeName() == other.TypeName()
+}
+
+// CheckControllerPath makes sure the path is absolute and clean
+func CheckControllerPath(p string) error {
+	if !strings.HasPrefix(p, "/") {
+		return fmt.Errorf("%w %q: paths must begin with a slash", ErrInvalidPath, p)
+	}
+
+	if path.Clean(p) != p {
+		return fmt.Errorf("%w %q: expected %q", ErrInvalidPath, p, path.Clean(p))
+	}
+	return nil
+}
+
+// pathsOverlap determines if one path is the same as,
+// or is a parent of, the other
+func pathsOverlap(p1, p2 string) bool {
+	if p1 == p2 || p1 == "/" || p2 == "/" {
+		return true
+	}
+	return strings.HasPrefix(p1, p2+"/") || strings.HasPrefix(p2, p1+"/")
+}
+
+// Controller looks up a controller by name
+func (c *Config) Controller(name string) (Controller, bool) {
+	for _, ctrl := range c.Controllers {
+		if ctrl.Name == name {
+			return ctrl, true
+		}
+	}
+	return Controller{}, false
+}
+
+// AddController adds a new controller to the project.  An error is
+// returned if the name is invalid or already used, or if the path
+// overlaps the path of an existing controller
+func (c *Config) AddController(name, path, desc string) error {
+	err := CheckName(name)
+	if err == nil {
+		err = CheckControllerPath(path)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, ctrl := range c.Controllers {
+		if ctrl.collides(Controller{Name: name}) {
+			return fmt.Errorf("%w: %q", ErrControllerExists, ctrl.Name)
+		} else if pathsOverlap(ctrl.Path, path) {
+			return fmt.Errorf("%w: %q overlaps %q (%s)", ErrControllerOverlap, path, ctrl.Path, ctrl.Name)
+		}
+	}
+
+	c.Controllers = append(c.Controllers, Controller{Name: name, Path: path, Desc: desc})
+	return nil
+}
+
+// RemoveController removes the named controller from the project
+func (c *Config) RemoveController(name string) error {
+	for i, ctrl := range c.Controllers {
+		if ctrl.Name == name {
+			c.Controllers = append(c.Controllers[:i], c.Controllers[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrControllerNotFound, name)
+}
+
+// updateAPITags records each controller as a tag in the OpenAPI
+// document.  Tags that were created for controllers that no longer
+// exist are removed, any other tags are left alone
+func (c *Config) updateAPITags() {
+	tags := openapi3.Tags{}
+	for _, tag := range c.apiConfig.Tags {
+		if _, found := tag.Extensions[pathPrefixExt]; found {
+			continue
+		} else if _, found := c.Controller(tag.Name); found {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+
+	for _, ctrl := range c.Controllers {
+		tags = append(tags, &openapi3.Tag{
+			ExtensionProps: openapi3.ExtensionProps{
+				Extensions: map[string]interface{}{pathPrefixExt: ctrl.Path},
+			},
+			Name:        ctrl.Name,
+			Description: ctrl.Desc,
+		})
+	}
+
+	c.apiConfig.Tags = tags
+	if len(tags) == 0 {
+		c.apiConfig.Tags = nil
+	}
+}
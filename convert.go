@@ -0,0 +1,327 @@
+package waffle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// OpenAPI30Version is the version written when exporting an
+	// OpenAPI 3.0 copy of the api config file.  It is also the version
+	// of the document while it is loaded since kin-openapi uses 3.0
+	// semantics
+	OpenAPI30Version = "3.0.3"
+
+	ErrUnsupportedAPIVersion = WaffleError("unsupported OpenAPI version")
+)
+
+// schemaKeywords31 are JSON Schema keywords that are allowed in
+// OpenAPI 3.1 schemas but have no OpenAPI 3.0 equivalent
+var schemaKeywords31 = []string{
+	"$anchor", "$comment", "$defs", "$dynamicAnchor", "$dynamicRef", "$id", "$schema",
+	"contains", "contentEncoding", "contentMediaType", "contentSchema",
+	"dependentRequired", "dependentSchemas", "else", "if", "maxContains",
+	"minContains", "patternProperties", "prefixItems", "propertyNames", "then",
+	"unevaluatedItems", "unevaluatedProperties",
+}
+
+// apiVersion returns the version declared by the
+// openapi field of a JSON encoded document
+func apiVersion(content []byte) string {
+	doc := struct {
+		OpenAPI string `json:"openapi"`
+	}{}
+	json.Unmarshal(content, &doc)
+	return doc.OpenAPI
+}
+
+type jsonObject = map[string]interface{}
+
+// converter walks the schemas of an OpenAPI document
+// (decoded as generic JSON) and rewrites them
+type converter struct {
+	warnings []string
+	schemaFn func(pointer string, schema jsonObject)
+}
+
+func (cv *converter) warn(pointer, format string, v ...interface{}) {
+	if pointer == "" {
+		pointer = "/"
+	}
+	cv.warnings = append(cv.warnings, fmt.Sprintf("%s: %s", pointer, fmt.Sprintf(format, v...)))
+}
+
+// each calls fn for every member of m that is itself an object
+// in key order so that warnings are reported consistently
+func each(m interface{}, pointer string, fn func(string, jsonObject)) {
+	switch m := m.(type) {
+	case jsonObject:
+		keys := []string{}
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if obj, ok := m[key].(jsonObject); ok {
+				fn(pointer+"/"+escapePointer(key), obj)
+			}
+		}
+	case []interface{}:
+		for i, v := range m {
+			if obj, ok := v.(jsonObject); ok {
+				fn(fmt.Sprintf("%s/%d", pointer, i), obj)
+			}
+		}
+	}
+}
+
+func (cv *converter) schema(pointer string, schema jsonObject) {
+	if _, found := schema["$ref"]; found {
+		return
+	}
+
+	cv.schemaFn(pointer, schema)
+	each(schema["properties"], pointer+"/properties", cv.schema)
+	for _, key := range []string{"items", "additionalProperties", "not"} {
+		if sub, ok := schema[key].(jsonObject); ok {
+			cv.schema(pointer+"/"+key, sub)
+		}
+	}
+
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		each(schema[key], pointer+"/"+key, cv.schema)
+	}
+}
+
+func (cv *converter) content(pointer string, content interface{}) {
+	each(content, pointer, func(pointer string, mediaType jsonObject) {
+		if schema, ok := mediaType["schema"].(jsonObject); ok {
+			cv.schema(pointer+"/schema", schema)
+		}
+	})
+}
+
+// parameter handles parameters and headers
+func (cv *converter) parameter(pointer string, param jsonObject) {
+	if schema, ok := param["schema"].(jsonObject); ok {
+		cv.schema(pointer+"/schema", schema)
+	}
+	cv.content(pointer+"/content", param["content"])
+}
+
+// body handles request bodies and responses
+func (cv *converter) body(pointer string, body jsonObject) {
+	cv.content(pointer+"/content", body["content"])
+	each(body["headers"], pointer+"/headers", cv.parameter)
+}
+
+func (cv *converter) pathItem(pointer string, item jsonObject) {
+	each(item["parameters"], pointer+"/parameters", cv.parameter)
+	for _, method := range []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"} {
+		op, ok := item[method].(jsonObject)
+		if !ok {
+			continue
+		}
+
+		opPointer := pointer + "/" + method
+		each(op["parameters"], opPointer+"/parameters", cv.parameter)
+		if body, ok := op["requestBody"].(jsonObject); ok {
+			cv.body(opPointer+"/requestBody", body)
+		}
+		each(op["responses"], opPointer+"/responses", cv.body)
+		each(op["callbacks"], opPointer+"/callbacks", func(pointer string, callback jsonObject) {
+			each(callback, pointer, cv.pathItem)
+		})
+	}
+}
+
+func (cv *converter) document(doc jsonObject) {
+	each(doc["paths"], "/paths", cv.pathItem)
+	if components, ok := doc["components"].(jsonObject); ok {
+		each(components["schemas"], "/components/schemas", cv.schema)
+		each(components["parameters"], "/components/parameters", cv.parameter)
+		each(components["headers"], "/components/headers", cv.parameter)
+		each(components["requestBodies"], "/components/requestBodies", cv.body)
+		each(components["responses"], "/components/responses", cv.body)
+		each(components["callbacks"], "/components/callbacks", func(pointer string, callback jsonObject) {
+			each(callback, pointer, cv.pathItem)
+		})
+	}
+}
+
+func (cv *converter) convert(content []byte, version string, fn func(doc jsonObject)) ([]byte, error) {
+	doc := jsonObject{}
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	err := dec.Decode(&doc)
+	if err == nil {
+		if fn != nil {
+			fn(doc)
+		}
+		cv.document(doc)
+		doc["openapi"] = version
+		content, err = json.MarshalIndent(doc, "", "  ")
+	}
+	return content, err
+}
+
+// UpgradeAPI converts a JSON encoded OpenAPI 3.0 document to 3.1.
+// Nullable types become type arrays that include "null" and boolean
+// exclusive bounds become numeric bounds.  Warnings are returned for
+// any schema that can't be represented exactly
+func UpgradeAPI(content []byte) ([]byte, []string, error) {
+	cv := &converter{}
+	cv.schemaFn = func(pointer string, schema jsonObject) {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			if t, ok := schema["type"].(string); ok {
+				schema["type"] = []interface{}{t, "null"}
+			} else {
+				cv.warn(pointer, "nullable without a type can not be represented in OpenAPI 3.1 and was dropped")
+			}
+		}
+		delete(schema, "nullable")
+
+		for _, bound := range []string{"Minimum", "Maximum"} {
+			exclusive := "exclusive" + bound
+			inclusive := strings.ToLower(bound)
+			if b, ok := schema[exclusive].(bool); ok {
+				if value, found := schema[inclusive]; b && found {
+					schema[exclusive] = value
+					delete(schema, inclusive)
+				} else {
+					delete(schema, exclusive)
+				}
+			}
+		}
+	}
+
+	content, err := cv.convert(content, OpenAPIVersion, nil)
+	return content, cv.warnings, err
+}
+
+// DowngradeAPI converts a JSON encoded OpenAPI 3.1 document to 3.0.
+// Type arrays that include "null" become nullable types, type arrays
+// with several types become anyOf lists and numeric exclusive bounds
+// become boolean bounds.  When strict is false, keywords that only
+// exist in 3.1 are left alone so that they can be carried through
+// kin-openapi (as extensions) and saved again.  When strict is true
+// those keywords are removed so the document can be read by tools that
+// only understand 3.0.  Warnings are returned for anything that does
+// not convert exactly
+func DowngradeAPI(content []byte, strict bool) ([]byte, []string, error) {
+	cv := &converter{}
+	cv.schemaFn = func(pointer string, schema jsonObject) {
+		if types, ok := schema["type"].([]interface{}); ok {
+			nonNull := []interface{}{}
+			for _, t := range types {
+				if t == "null" {
+					schema["nullable"] = true
+				} else {
+					nonNull = append(nonNull, t)
+				}
+			}
+
+			switch len(nonNull) {
+			case 0:
+				cv.warn(pointer, "the null type can not be represented in OpenAPI 3.0 and was dropped")
+				delete(schema, "type")
+			case 1:
+				schema["type"] = nonNull[0]
+			default:
+				anyOf := []interface{}{}
+				for _, t := range nonNull {
+					anyOf = append(anyOf, jsonObject{"type": t})
+				}
+				schema["anyOf"] = anyOf
+				delete(schema, "type")
+				cv.warn(pointer, "multiple types were rewritten as anyOf")
+			}
+		} else if schema["type"] == "null" {
+			cv.warn(pointer, "the null type can not be represented in OpenAPI 3.0 and was dropped")
+			delete(schema, "type")
+		}
+
+		for _, bound := range []string{"Minimum", "Maximum"} {
+			exclusive := "exclusive" + bound
+			if value, ok := schema[exclusive].(json.Number); ok {
+				if _, found := schema[strings.ToLower(bound)]; found {
+					cv.warn(pointer, "both %s and %s are set, only %s is kept", exclusive, strings.ToLower(bound), exclusive)
+				}
+				schema[strings.ToLower(bound)] = value
+				schema[exclusive] = true
+			}
+		}
+
+		if !strict {
+			return
+		}
+
+		if value, found := schema["const"]; found {
+			schema["enum"] = []interface{}{value}
+			delete(schema, "const")
+		}
+
+		if examples, ok := schema["examples"].([]interface{}); ok {
+			if len(examples) > 0 {
+				schema["example"] = examples[0]
+			}
+
+			if len(examples) > 1 {
+				cv.warn(pointer, "only the first of %d examples was kept", len(examples))
+			}
+			delete(schema, "examples")
+		}
+
+		for _, keyword := range schemaKeywords31 {
+			if _, found := schema[keyword]; found {
+				cv.warn(pointer, "%s is not supported by OpenAPI 3.0 and was dropped", keyword)
+				delete(schema, keyword)
+			}
+		}
+	}
+
+	content, err := cv.convert(content, OpenAPI30Version, func(doc jsonObject) {
+		// paths are optional in 3.1, but required in 3.0
+		if _, found := doc["paths"]; !found {
+			doc["paths"] = jsonObject{}
+		}
+
+		if !strict {
+			return
+		}
+
+		for _, key := range []string{"webhooks", "jsonSchemaDialect"} {
+			if _, found := doc[key]; found {
+				cv.warn("/"+key, "not supported by OpenAPI 3.0 and was dropped")
+				delete(doc, key)
+			}
+		}
+
+		if info, ok := doc["info"].(jsonObject); ok {
+			if _, found := info["summary"]; found {
+				cv.warn("/info/summary", "not supported by OpenAPI 3.0 and was dropped")
+				delete(info, "summary")
+			}
+
+			if license, ok := info["license"].(jsonObject); ok {
+				if _, found := license["identifier"]; found {
+					cv.warn("/info/license/identifier", "not supported by OpenAPI 3.0 and was dropped")
+					delete(license, "identifier")
+				}
+			}
+		}
+
+		if components, ok := doc["components"].(jsonObject); ok {
+			if _, found := components["pathItems"]; found {
+				cv.warn("/components/pathItems", "not supported by OpenAPI 3.0 and was dropped")
+				delete(components, "pathItems")
+			}
+		}
+
+	})
+	return content, cv.warnings, err
+}
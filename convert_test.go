@@ -0,0 +1,239 @@
+package waffle
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// convertSchema converts a document with a single component
+// schema and returns the converted schema
+func convertSchema(t *testing.T, convert func([]byte) ([]byte, []string, error), version, schema string) (interface{}, []string) {
+	t.Helper()
+	doc := `{"openapi": "` + version + `", "info": {"title": "test", "version": "1.0.0"}, "paths": {}, "components": {"schemas": {"S": ` + schema + `}}}`
+	content, warnings, err := convert([]byte(doc))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	converted := struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}{}
+
+	if err = json.Unmarshal(content, &converted); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return converted.Components.Schemas["S"], warnings
+}
+
+func decodeJSON(t *testing.T, str string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(str), &v); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", str, err)
+	}
+	return v
+}
+
+func TestUpgradeAPI(t *testing.T) {
+	tests := []struct {
+		name         string
+		schema       string
+		want         string
+		wantWarnings []string
+	}{
+		{"unchanged", `{"type": "string"}`, `{"type": "string"}`, nil},
+		{"nullable", `{"type": "string", "nullable": true}`, `{"type": ["string", "null"]}`, nil},
+		{"not nullable", `{"type": "string", "nullable": false}`, `{"type": "string"}`, nil},
+		{"nullable without type", `{"nullable": true}`, `{}`, []string{"/components/schemas/S: nullable without a type can not be represented in OpenAPI 3.1 and was dropped"}},
+		{"exclusive minimum", `{"type": "integer", "minimum": 1, "exclusiveMinimum": true}`, `{"type": "integer", "exclusiveMinimum": 1}`, nil},
+		{"inclusive maximum", `{"type": "integer", "maximum": 9, "exclusiveMaximum": false}`, `{"type": "integer", "maximum": 9}`, nil},
+		{"exclusive without bound", `{"type": "integer", "exclusiveMaximum": true}`, `{"type": "integer"}`, nil},
+		{
+			"nested",
+			`{"type": "object", "properties": {"a": {"type": "array", "items": {"type": "integer", "nullable": true}}}}`,
+			`{"type": "object", "properties": {"a": {"type": "array", "items": {"type": ["integer", "null"]}}}}`,
+			nil,
+		},
+		{
+			"nested warning",
+			`{"allOf": [{"type": "object"}, {"nullable": true}]}`,
+			`{"allOf": [{"type": "object"}, {}]}`,
+			[]string{"/components/schemas/S/allOf/1: nullable without a type can not be represented in OpenAPI 3.1 and was dropped"},
+		},
+		{"reference", `{"$ref": "#/components/schemas/T", "nullable": true}`, `{"$ref": "#/components/schemas/T", "nullable": true}`, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, warnings := convertSchema(t, UpgradeAPI, OpenAPI30Version, test.schema)
+			if want := decodeJSON(t, test.want); !reflect.DeepEqual(want, got) {
+				t.Errorf("Wanted %v got %v", want, got)
+			}
+
+			if !reflect.DeepEqual(test.wantWarnings, warnings) {
+				t.Errorf("Wanted warnings %q got %q", test.wantWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestDowngradeAPI(t *testing.T) {
+	tests := []struct {
+		name         string
+		strict       bool
+		schema       string
+		want         string
+		wantWarnings []string
+	}{
+		{"unchanged", false, `{"type": "string"}`, `{"type": "string"}`, nil},
+		{"nullable", false, `{"type": ["string", "null"]}`, `{"type": "string", "nullable": true}`, nil},
+		{"single type array", false, `{"type": ["string"]}`, `{"type": "string"}`, nil},
+		{
+			"multiple types", false,
+			`{"type": ["string", "integer", "null"]}`,
+			`{"anyOf": [{"type": "string"}, {"type": "integer"}], "nullable": true}`,
+			[]string{"/components/schemas/S: multiple types were rewritten as anyOf"},
+		},
+		{"null type", false, `{"type": "null"}`, `{}`, []string{"/components/schemas/S: the null type can not be represented in OpenAPI 3.0 and was dropped"}},
+		{"null type array", false, `{"type": ["null"]}`, `{"nullable": true}`, []string{"/components/schemas/S: the null type can not be represented in OpenAPI 3.0 and was dropped"}},
+		{"exclusive minimum", false, `{"type": "integer", "exclusiveMinimum": 1}`, `{"type": "integer", "minimum": 1, "exclusiveMinimum": true}`, nil},
+		{
+			"exclusive and inclusive bounds", false,
+			`{"type": "integer", "maximum": 10, "exclusiveMaximum": 9}`,
+			`{"type": "integer", "maximum": 9, "exclusiveMaximum": true}`,
+			[]string{"/components/schemas/S: both exclusiveMaximum and maximum are set, only exclusiveMaximum is kept"},
+		},
+		{"3.1 keywords kept", false, `{"type": "string", "const": "a", "$comment": "c"}`, `{"type": "string", "const": "a", "$comment": "c"}`, nil},
+		{"const", true, `{"type": "string", "const": "a"}`, `{"type": "string", "enum": ["a"]}`, nil},
+		{"single example", true, `{"type": "string", "examples": ["a"]}`, `{"type": "string", "example": "a"}`, nil},
+		{
+			"examples", true,
+			`{"type": "string", "examples": ["a", "b"]}`,
+			`{"type": "string", "example": "a"}`,
+			[]string{"/components/schemas/S: only the first of 2 examples was kept"},
+		},
+		{
+			"3.1 keywords dropped", true,
+			`{"type": "object", "properties": {"a": {"type": "string", "$comment": "c", "if": {}}}}`,
+			`{"type": "object", "properties": {"a": {"type": "string"}}}`,
+			[]string{
+				"/components/schemas/S/properties/a: $comment is not supported by OpenAPI 3.0 and was dropped",
+				"/components/schemas/S/properties/a: if is not supported by OpenAPI 3.0 and was dropped",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			downgrade := func(content []byte) ([]byte, []string, error) { return DowngradeAPI(content, test.strict) }
+			got, warnings := convertSchema(t, downgrade, OpenAPIVersion, test.schema)
+			if want := decodeJSON(t, test.want); !reflect.DeepEqual(want, got) {
+				t.Errorf("Wanted %v got %v", want, got)
+			}
+
+			if !reflect.DeepEqual(test.wantWarnings, warnings) {
+				t.Errorf("Wanted warnings %q got %q", test.wantWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestDowngradeAPIDocument(t *testing.T) {
+	doc := `{
+		"openapi": "3.1.0",
+		"jsonSchemaDialect": "https://spec.openapis.org/oas/3.1/dialect/base",
+		"info": {"title": "test", "version": "1.0.0", "summary": "s", "license": {"name": "MIT", "identifier": "MIT"}},
+		"webhooks": {},
+		"components": {"pathItems": {}}
+	}`
+
+	tests := []struct {
+		name         string
+		strict       bool
+		want         string
+		wantWarnings []string
+	}{
+		{
+			"lenient", false,
+			`{
+				"openapi": "3.0.3",
+				"jsonSchemaDialect": "https://spec.openapis.org/oas/3.1/dialect/base",
+				"info": {"title": "test", "version": "1.0.0", "summary": "s", "license": {"name": "MIT", "identifier": "MIT"}},
+				"webhooks": {},
+				"paths": {},
+				"components": {"pathItems": {}}
+			}`,
+			nil,
+		},
+		{
+			"strict", true,
+			`{
+				"openapi": "3.0.3",
+				"info": {"title": "test", "version": "1.0.0", "license": {"name": "MIT"}},
+				"paths": {},
+				"components": {}
+			}`,
+			[]string{
+				"/webhooks: not supported by OpenAPI 3.0 and was dropped",
+				"/jsonSchemaDialect: not supported by OpenAPI 3.0 and was dropped",
+				"/info/summary: not supported by OpenAPI 3.0 and was dropped",
+				"/info/license/identifier: not supported by OpenAPI 3.0 and was dropped",
+				"/components/pathItems: not supported by OpenAPI 3.0 and was dropped",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, warnings, err := DowngradeAPI([]byte(doc), test.strict)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if want, got := decodeJSON(t, test.want), decodeJSON(t, string(content)); !reflect.DeepEqual(want, got) {
+				t.Errorf("Wanted %v got %v", want, got)
+			}
+
+			if !reflect.DeepEqual(test.wantWarnings, warnings) {
+				t.Errorf("Wanted warnings %q got %q", test.wantWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	schema := `{"type": "object", "properties": {"a": {"type": "integer", "nullable": true, "minimum": 0, "exclusiveMinimum": true}}}`
+	upgraded, warnings := convertSchema(t, UpgradeAPI, OpenAPI30Version, schema)
+	if len(warnings) > 0 {
+		t.Errorf("Unexpected warnings: %q", warnings)
+	}
+
+	content, err := json.Marshal(upgraded)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	downgrade := func(content []byte) ([]byte, []string, error) { return DowngradeAPI(content, true) }
+	got, warnings := convertSchema(t, downgrade, OpenAPIVersion, string(content))
+	if len(warnings) > 0 {
+		t.Errorf("Unexpected warnings: %q", warnings)
+	}
+
+	if want := decodeJSON(t, schema); !reflect.DeepEqual(want, got) {
+		t.Errorf("Wanted %v got %v", want, got)
+	}
+}
+
+func TestConvertInvalid(t *testing.T) {
+	if _, _, err := UpgradeAPI([]byte("{")); err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	if _, _, err := DowngradeAPI([]byte("[]"), false); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
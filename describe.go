@@ -0,0 +1,147 @@
+package waffle
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Description locates a commit relative to the nearest release
+// that it was built from, similar to git describe
+type Description struct {
+	// Release is the nearest release reachable from the commit or
+	// nil if no release is reachable
+	Release *Release
+
+	// Commits is the number of commits since the release
+	Commits int
+
+	// Commit is the described commit
+	Commit *object.Commit
+}
+
+// Version returns the release version if the commit is tagged.  Otherwise
+// a go pseudo-version is returned that sorts after the release and before
+// the next release (ie v1.2.4-0.20261018120000-abcdef123456)
+func (d Description) Version() Version {
+	if d.Release != nil && d.Commits == 0 {
+		return d.Release.Version
+	}
+
+	stamp := fmt.Sprintf("%s-%s", d.Commit.Committer.When.UTC().Format("20060102150405"), d.Commit.Hash.String()[:12])
+	if d.Release == nil {
+		return Version{PreRelease: stamp}
+	}
+
+	v := d.Release.Version
+	v.Build = ""
+	if v.IsPreRelease() {
+		v.PreRelease = fmt.Sprintf("%s.0.%s", v.PreRelease, stamp)
+	} else {
+		v.Patch++
+		v.PreRelease = "0." + stamp
+	}
+	return v
+}
+
+// String returns the description in the form used by git describe
+// (ie v1.2.3-4-gabcdef1)
+func (d Description) String() string {
+	hash := d.Commit.Hash.String()[:7]
+	if d.Release == nil {
+		return hash
+	} else if d.Commits == 0 {
+		return d.Release.Tag
+	}
+	return fmt.Sprintf("%s-%d-g%s", d.Release.Tag, d.Commits, hash)
+}
+
+// Describe finds the release nearest to HEAD.  Only releases that are
+// reachable from HEAD are considered, so releases on other branches
+// are ignored.  The nearest release is the one with the shortest path
+// from HEAD, if there is a tie the highest version is used
+func (gr *GitRepo) Describe() (d Description, err error) {
+	head, err := gr.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		// no commits yet
+		return d, ErrNoGitVersion
+	}
+
+	if err == nil {
+		d.Commit, err = gr.repo.CommitObject(head.Hash())
+	}
+
+	var releases []Release
+	if err == nil {
+		releases, err = gr.Releases()
+	}
+
+	// releases are sorted by version, so the highest version
+	// wins when a commit has more than one release tag
+	tagged := make(map[plumbing.Hash]*Release)
+	for i := range releases {
+		tagged[releases[i].Commit.Hash] = &releases[i]
+	}
+
+	// search breadth first from HEAD and stop at the first
+	// level that has a tagged commit
+	level := []*object.Commit{d.Commit}
+	seen := map[plumbing.Hash]bool{}
+	for err == nil && d.Release == nil && len(tagged) > 0 && len(level) > 0 {
+		var parents []*object.Commit
+		for _, commit := range level {
+			if release := tagged[commit.Hash]; release != nil {
+				if d.Release == nil || d.Release.Version.Compare(release.Version) < 0 {
+					d.Release = release
+				}
+				continue
+			}
+
+			err = commit.Parents().ForEach(func(parent *object.Commit) error {
+				if !seen[parent.Hash] {
+					seen[parent.Hash] = true
+					parents = append(parents, parent)
+				}
+				return nil
+			})
+			if err != nil {
+				break
+			}
+		}
+		level = parents
+	}
+
+	if err == nil && d.Release != nil {
+		var commits []*object.Commit
+		commits, err = commitsBetween(d.Release.Commit, d.Commit)
+		d.Commits = len(commits)
+	}
+	return
+}
+
+// PseudoVersion is the version of HEAD.  This is the version of the
+// nearest release if HEAD is tagged, otherwise it is a go pseudo-version
+// based on the nearest release
+func (gr *GitRepo) PseudoVersion() (Version, error) {
+	d, err := gr.Describe()
+	if err == nil {
+		return d.Version(), nil
+	}
+	return Version{}, err
+}
+
+// GitVersion returns the version of the project's git HEAD as a go
+// module version (ie v1.2.3 or v1.2.4-0.20261018120000-abcdef123456) so
+// that templates can include it.  If the version can't be determined
+// from git then the version in the config is returned
+func (c *Config) GitVersion() string {
+	repo, err := OpenGit(c.Dir())
+	if err == nil {
+		var v Version
+		if v, err = repo.PseudoVersion(); err == nil {
+			return v.Tag()
+		}
+	}
+	return c.Module.Version.Tag()
+}
@@ -0,0 +1,252 @@
+package waffle
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// newDescribeHistory creates the history:
+//
+//	A(v0.1.0) - B(v0.2.0) - C(api/v0.5.0) - D(latest)   main
+//	             \- S1(v1.0.0) - S2                      side
+//	             \- T1(v0.2.1) -\
+//	             \- T2(v0.2.2) - M                       merge
+//	             \- R1(v0.3.0-rc.1) - R2                 rc
+func newDescribeHistory(t *testing.T) *testHistory {
+	th := newTestHistory(t)
+	th.commit("A", "A")
+	th.commit("B", "B", "A")
+	th.commit("C", "C", "B")
+	th.commit("D", "D", "C")
+	th.commit("S1", "S1", "B")
+	th.commit("S2", "S2", "S1")
+	th.commit("T1", "T1", "B")
+	th.commit("T2", "T2", "B")
+	th.commit("M", "M", "T1", "T2")
+	th.commit("R1", "R1", "B")
+	th.commit("R2", "R2", "R1")
+
+	th.tag("v0.1.0", "A", "")
+	th.tag("v0.2.0", "B", "Release v0.2.0")
+	th.tag("api/v0.5.0", "C", "Release api/v0.5.0")
+	th.tag("latest", "D", "")
+	th.tag("v1.0.0", "S1", "Release v1.0.0")
+	th.tag("v0.2.1", "T1", "")
+	th.tag("v0.2.2", "T2", "")
+	th.tag("v0.3.0-rc.1", "R1", "Release v0.3.0-rc.1")
+	return th
+}
+
+func TestReleases(t *testing.T) {
+	tests := []struct {
+		prefix string
+		// want is each release tag and the commit it tags
+		want []string
+	}{
+		{"", []string{"v0.1.0 A", "v0.2.0 B", "v0.2.1 T1", "v0.2.2 T2", "v0.3.0-rc.1 R1", "v1.0.0 S1"}},
+		{"api/", []string{"api/v0.5.0 C"}},
+		{"cmd/", nil},
+	}
+
+	th := newDescribeHistory(t)
+	commits := make(map[plumbing.Hash]string)
+	for name, hash := range th.names {
+		commits[hash] = name
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("prefix %q", test.prefix), func(t *testing.T) {
+			repo := th.checkout("D")
+			repo.prefix = test.prefix
+			releases, err := repo.Releases()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, release := range releases {
+				got = append(got, release.Tag+" "+commits[release.Commit.Hash])
+			}
+
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("Wanted %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		name        string
+		head        string
+		prefix      string
+		wantRelease string
+		wantCommits int
+	}{
+		{"lightweight tag", "A", "", "v0.1.0", 0},
+		{"annotated tag", "B", "", "v0.2.0", 0},
+		{"release on another branch", "D", "", "v0.2.0", 2},
+		{"release on the branch", "S2", "", "v1.0.0", 1},
+		{"tie uses the highest version", "M", "", "v0.2.2", 2},
+		{"pre-release", "R2", "", "v0.3.0-rc.1", 1},
+		{"prefix", "D", "api/", "api/v0.5.0", 1},
+		{"no prefixed release", "D", "cmd/", "", 0},
+	}
+
+	th := newDescribeHistory(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := th.checkout(test.head)
+			repo.prefix = test.prefix
+			d, err := repo.Describe()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if d.Commit.Hash != th.names[test.head] {
+				t.Errorf("Wanted commit %v got %v", th.names[test.head], d.Commit.Hash)
+			}
+
+			got := ""
+			if d.Release != nil {
+				got = d.Release.Tag
+			}
+
+			if got != test.wantRelease {
+				t.Errorf("Wanted release %q got %q", test.wantRelease, got)
+			}
+
+			if d.Commits != test.wantCommits {
+				t.Errorf("Wanted %d commits got %d", test.wantCommits, d.Commits)
+			}
+
+			want := th.names[test.head].String()[:7]
+			if test.wantRelease != "" && test.wantCommits == 0 {
+				want = test.wantRelease
+			} else if test.wantRelease != "" {
+				want = fmt.Sprintf("%s-%d-g%s", test.wantRelease, test.wantCommits, want)
+			}
+
+			if d.String() != want {
+				t.Errorf("Wanted %q got %q", want, d.String())
+			}
+		})
+	}
+}
+
+func TestDescribeNoCommits(t *testing.T) {
+	th := newTestHistory(t)
+	if _, err := (&GitRepo{repo: th.repo}).Describe(); err != ErrNoGitVersion {
+		t.Errorf("Wanted %v got %v", ErrNoGitVersion, err)
+	}
+}
+
+func TestDescriptionVersion(t *testing.T) {
+	commit := &object.Commit{
+		Hash:      plumbing.NewHash("abcdef1234567890abcdef1234567890abcdef12"),
+		Committer: object.Signature{When: time.Date(2021, 10, 18, 12, 34, 56, 0, time.UTC)},
+	}
+
+	est := &object.Commit{
+		Hash:      commit.Hash,
+		Committer: object.Signature{When: time.Date(2021, 10, 18, 8, 34, 56, 0, time.FixedZone("EDT", -4*60*60))},
+	}
+
+	tests := []struct {
+		name    string
+		release string
+		commits int
+		commit  *object.Commit
+		want    string
+	}{
+		{"tagged", "1.2.3", 0, commit, "v1.2.3"},
+		{"tagged pre-release", "1.3.0-rc.1", 0, commit, "v1.3.0-rc.1"},
+		{"after release", "1.2.3", 2, commit, "v1.2.4-0.20211018123456-abcdef123456"},
+		{"after pre-release", "1.3.0-rc.1", 1, commit, "v1.3.0-rc.1.0.20211018123456-abcdef123456"},
+		{"build metadata", "1.2.3+build.5", 1, commit, "v1.2.4-0.20211018123456-abcdef123456"},
+		{"no release", "", 3, commit, "v0.0.0-20211018123456-abcdef123456"},
+		{"time zone", "1.2.3", 1, est, "v1.2.4-0.20211018123456-abcdef123456"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := Description{Commits: test.commits, Commit: test.commit}
+			if test.release != "" {
+				d.Release = &Release{Version: mustParseVersion(t, test.release), Tag: "v" + test.release}
+			}
+
+			got := d.Version()
+			if got.Tag() != test.want {
+				t.Errorf("Wanted %q got %q", test.want, got.Tag())
+			}
+
+			// pseudo-versions sort after the release
+			if d.Release != nil && test.commits > 0 && got.Compare(d.Release.Version) <= 0 {
+				t.Errorf("Expected %s to sort after %s", got.Tag(), d.Release.Version.Tag())
+			}
+		})
+	}
+}
+
+func TestRepoVersions(t *testing.T) {
+	tests := []struct {
+		head        string
+		prefix      string
+		wantCurrent string
+		// wantPseudo is formatted with the first 12 characters of the head hash
+		wantPseudo string
+	}{
+		{"B", "", "v0.2.0", "v0.2.0"},
+		{"D", "", "v0.2.0", "v0.2.1-0.20211018120400-%s"},
+		{"S2", "", "v1.0.0", "v1.0.1-0.20211018120600-%s"},
+		{"M", "", "v0.2.2", "v0.2.3-0.20211018120900-%s"},
+		{"R2", "", "v0.3.0-rc.1", "v0.3.0-rc.1.0.20211018121100-%s"},
+		{"D", "api/", "v0.5.0", "v0.5.1-0.20211018120400-%s"},
+		{"D", "cmd/", "", "v0.0.0-20211018120400-%s"},
+	}
+
+	th := newDescribeHistory(t)
+	for _, test := range tests {
+		t.Run(test.prefix+test.head, func(t *testing.T) {
+			repo := th.checkout(test.head)
+			repo.prefix = test.prefix
+			current, err := repo.CurrentVersion()
+			if test.wantCurrent == "" && err != ErrNoGitVersion {
+				t.Errorf("Wanted %v got %v", ErrNoGitVersion, err)
+			} else if test.wantCurrent != "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			} else if test.wantCurrent != "" && current.Tag() != test.wantCurrent {
+				t.Errorf("Wanted %q got %q", test.wantCurrent, current.Tag())
+			}
+
+			pseudo, err := repo.PseudoVersion()
+			want := test.wantPseudo
+			if strings.Contains(want, "%s") {
+				want = fmt.Sprintf(want, th.names[test.head].String()[:12])
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			} else if pseudo.Tag() != want {
+				t.Errorf("Wanted %q got %q", want, pseudo.Tag())
+			}
+		})
+	}
+}
+
+func TestRepoVersionsNoCommits(t *testing.T) {
+	repo := &GitRepo{repo: newTestHistory(t).repo}
+	if _, err := repo.CurrentVersion(); err != ErrNoGitVersion {
+		t.Errorf("Wanted %v got %v", ErrNoGitVersion, err)
+	}
+
+	if _, err := repo.PseudoVersion(); err != ErrNoGitVersion {
+		t.Errorf("Wanted %v got %v", ErrNoGitVersion, err)
+	}
+}
@@ -0,0 +1,267 @@
+package waffle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const (
+	// DefEnvironment is the environment that code is generated
+	// for when one isn't selected
+	DefEnvironment = "dev"
+
+	// DefEnvironmentFile records the environment that the project
+	// was last generated for
+	DefEnvironmentFile = ".waffle/environment"
+
+	ErrEnvironmentNotFound = WaffleError("environment not found")
+	ErrEnvironmentExists   = WaffleError("environment already exists")
+
+	// environmentExt is the OpenAPI server extension that records
+	// which environment a server was created for
+	environmentExt = "x-environment"
+)
+
+// Duration is a time.Duration that is written to JSON
+// as a string such as "15s" or "1m30s"
+type Duration time.Duration
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+// Set parses a duration string such as "15s"
+func (d *Duration) Set(str string) error {
+	v, err := time.ParseDuration(str)
+	if err == nil {
+		*d = Duration(v)
+	}
+	return err
+}
+
+// Literal returns the duration as a Go expression (ie 15 * time.Second)
+func (d Duration) Literal() string {
+	if d == 0 {
+		return "0"
+	}
+
+	units := []struct {
+		unit time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	}
+
+	for _, u := range units {
+		if time.Duration(d)%u.unit == 0 {
+			return fmt.Sprintf("%d * %s", time.Duration(d)/u.unit, u.name)
+		}
+	}
+	return fmt.Sprintf("%d", int64(d))
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var str string
+	err := json.Unmarshal(data, &str)
+	if err == nil {
+		err = d.Set(str)
+	}
+	return err
+}
+
+// Environment holds the settings for one of the places the API is
+// deployed, such as dev, staging or prod
+type Environment struct {
+	// Name identifies the environment
+	Name string `json:"name"`
+
+	// ListenOn is the list of addresses (host:port) the server listens on
+	ListenOn []string `json:"listenOn"`
+
+	// ReadTimeout is the maximum duration for reading a request
+	ReadTimeout Duration `json:"readTimeout"`
+
+	// WriteTimeout is the maximum duration for writing a response
+	WriteTimeout Duration `json:"writeTimeout"`
+
+	// BaseURL is the URL clients use to reach the API.  If set, it
+	// is listed as a server in the OpenAPI document
+	BaseURL string `json:"baseURL,omitempty"`
+}
+
+// NewEnvironment returns an environment with the default settings
+func NewEnvironment(name string) Environment {
+	return Environment{
+		Name:         name,
+		ListenOn:     []string{":8080"},
+		ReadTimeout:  Duration(15 * time.Second),
+		WriteTimeout: Duration(15 * time.Second),
+	}
+}
+
+// DefaultEnvironments are the environments that new projects start with
+func DefaultEnvironments() []Environment {
+	dev := NewEnvironment("dev")
+	dev.BaseURL = "http://localhost:8080"
+	return []Environment{dev, NewEnvironment("staging"), NewEnvironment("prod")}
+}
+
+// CheckListenAddress makes sure the address is of the
+// form host:port where the host is optional
+func CheckListenAddress(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err == nil && port == "" {
+		err = fmt.Errorf("address %q is missing a port", addr)
+	}
+	return err
+}
+
+// Ports returns the port of each address the server listens on
+func (e Environment) Ports() []string {
+	ports := []string{}
+	for _, addr := range e.ListenOn {
+		if _, port, err := net.SplitHostPort(addr); err == nil {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+func (e Environment) validate(ve *ValidationError, field string) {
+	ve.add(field+".name", CheckName(e.Name))
+	if len(e.ListenOn) == 0 {
+		ve.add(field+".listenOn", ErrRequired)
+	}
+
+	for i, addr := range e.ListenOn {
+		ve.add(fmt.Sprintf("%s.listenOn[%d]", field, i), CheckListenAddress(addr))
+	}
+
+	if e.ReadTimeout < 0 {
+		ve.add(field+".readTimeout", fmt.Errorf("%v must not be negative", e.ReadTimeout))
+	}
+
+	if e.WriteTimeout < 0 {
+		ve.add(field+".writeTimeout", fmt.Errorf("%v must not be negative", e.WriteTimeout))
+	}
+	ve.add(field+".baseURL", CheckURL(e.BaseURL))
+}
+
+// Environment looks up an environment by name
+func (c *Config) Environment(name string) (Environment, bool) {
+	for _, env := range c.Environments {
+		if env.Name == name {
+			return env, true
+		}
+	}
+	return Environment{}, false
+}
+
+// SelectEnvironment chooses the environment that code is generated for
+func (c *Config) SelectEnvironment(name string) error {
+	if _, found := c.Environment(name); !found {
+		names := []string{}
+		for _, env := range c.Environments {
+			names = append(names, env.Name)
+		}
+		return fmt.Errorf("%w: %q, expected one of %s", ErrEnvironmentNotFound, name, strings.Join(names, ", "))
+	}
+	c.environment = name
+	return nil
+}
+
+// SelectGeneratedEnvironment chooses the environment that the project in
+// root was last generated for.  Nothing is selected if the project hasn't
+// been generated or the environment no longer exists
+func (c *Config) SelectGeneratedEnvironment(root string) error {
+	content, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(DefEnvironmentFile)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	name := strings.TrimSpace(string(content))
+	if _, found := c.Environment(name); err == nil && found {
+		c.environment = name
+	}
+	return err
+}
+
+// WriteGeneratedEnvironment records the environment that code is
+// generated for, so that the next generation uses the same one
+func (c *Config) WriteGeneratedEnvironment(root string) error {
+	filename := filepath.Join(root, filepath.FromSlash(DefEnvironmentFile))
+	var err error
+	if !checking {
+		err = os.MkdirAll(filepath.Dir(filename), 0755)
+	}
+
+	if err == nil {
+		err = WriteFile(filename, []byte(c.Env().Name+"\n"))
+	}
+	return err
+}
+
+// Env is the environment that code is generated for.  This is the
+// selected environment, or DefEnvironment if none has been selected.  If
+// the project doesn't have that environment then the first environment
+// is used and if the project has no environments the defaults are used
+func (c *Config) Env() Environment {
+	name := c.environment
+	if name == "" {
+		name = DefEnvironment
+	}
+
+	if env, found := c.Environment(name); found {
+		return env
+	} else if len(c.Environments) > 0 {
+		return c.Environments[0]
+	}
+	return NewEnvironment(name)
+}
+
+// updateAPIServers lists the base URL of each environment as
+// a server in the OpenAPI document.  Servers that were not created
+// for an environment are left alone
+func (c *Config) updateAPIServers() {
+	servers := openapi3.Servers{}
+	for _, server := range c.apiConfig.Servers {
+		if _, found := server.Extensions[environmentExt]; !found {
+			servers = append(servers, server)
+		}
+	}
+
+	for _, env := range c.Environments {
+		if env.BaseURL == "" {
+			continue
+		}
+
+		servers = append(servers, &openapi3.Server{
+			ExtensionProps: openapi3.ExtensionProps{
+				Extensions: map[string]interface{}{environmentExt: env.Name},
+			},
+			URL:         env.BaseURL,
+			Description: env.Name,
+		})
+	}
+
+	c.apiConfig.Servers = servers
+	if len(servers) == 0 {
+		c.apiConfig.Servers = nil
+	}
+}
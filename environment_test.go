@@ -0,0 +1,69 @@
+package waffle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeneratedEnvironment(t *testing.T) {
+	tests := []struct {
+		name    string
+		written string
+		want    string
+	}{
+		{"not generated", "", DefEnvironment},
+		{"generated", "prod\n", "prod"},
+		{"removed environment", "staging\n", DefEnvironment},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			if test.written != "" {
+				writeTestFile(t, filepath.Join(root, filepath.FromSlash(DefEnvironmentFile)), test.written)
+			}
+
+			c := &Config{Environments: []Environment{NewEnvironment(DefEnvironment), NewEnvironment("prod")}}
+			if err := c.SelectGeneratedEnvironment(root); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got := c.Env().Name; got != test.want {
+				t.Errorf("Wanted %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestWriteGeneratedEnvironment(t *testing.T) {
+	root := t.TempDir()
+	c := &Config{Environments: []Environment{NewEnvironment(DefEnvironment), NewEnvironment("prod")}}
+	err := c.SelectEnvironment("prod")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// checking must not create the .waffle directory
+	files, err := CheckWrites(func() error { return c.WriteGeneratedEnvironment(root) })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	filename := filepath.Join(root, filepath.FromSlash(DefEnvironmentFile))
+	if len(files) != 1 || files[0] != filename {
+		t.Errorf("Wanted [%s] got %v", filename, files)
+	}
+
+	if _, err := os.Stat(filepath.Dir(filename)); !os.IsNotExist(err) {
+		t.Errorf("Expected %s not to exist got %v", filepath.Dir(filename), err)
+	}
+
+	if err = c.WriteGeneratedEnvironment(root); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := readTestFile(t, filename); got != "prod\n" {
+		t.Errorf("Wanted %q got %q", "prod\n", got)
+	}
+}
@@ -0,0 +1,79 @@
+package waffle
+
+import (
+	"fmt"
+	"strings"
+)
+
+const ErrUnknownFeature = WaffleError("unknown feature")
+
+// Feature is an optional part of a project that init sets up
+type Feature struct {
+	// Name identifies the feature in the project config
+	Name string
+	// Desc describes what the feature adds
+	Desc string
+}
+
+// AllFeatures is the list of optional features
+var AllFeatures = []Feature{
+	{"docker", "Dockerfile and docker-compose.yml for running the server"},
+	{"hooks", "git hooks that check generated code before commits and pushes"},
+}
+
+// CheckFeature makes sure the feature is one of AllFeatures
+func CheckFeature(name string) error {
+	names := []string{}
+	for _, feature := range AllFeatures {
+		if feature.Name == name {
+			return nil
+		}
+		names = append(names, feature.Name)
+	}
+	return fmt.Errorf("%w %q, expected one of %s", ErrUnknownFeature, name, strings.Join(names, ", "))
+}
+
+// Features is the list of features enabled for a project.  Features
+// satisfies the flag.Value interface and accepts a comma separated
+// list that replaces the enabled features, the same as setting any
+// other field
+type Features []string
+
+func (f *Features) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *Features) Set(str string) error {
+	features := Features{}
+	for _, name := range strings.Split(str, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+
+		if err := CheckFeature(name); err != nil {
+			return err
+		}
+
+		if !features.Has(name) {
+			features = append(features, name)
+		}
+	}
+	*f = features
+	return nil
+}
+
+// Has returns true if the feature is enabled
+func (f Features) Has(name string) bool {
+	for _, feature := range f {
+		if feature == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasFeature returns true if the project has the feature enabled so
+// that templates can include optional files
+func (c *Config) HasFeature(name string) bool {
+	return c.Features.Has(name)
+}
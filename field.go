@@ -0,0 +1,220 @@
+package waffle
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	ErrUnknownField = WaffleError("unknown config field")
+	ErrInvalidField = WaffleError("invalid config field")
+)
+
+// fieldName returns the name of a struct field in JSON or
+// an empty string if the field isn't encoded
+func fieldName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	} else if name == "" {
+		name = field.Name
+	}
+	return name
+}
+
+// fieldNames lists the JSON names of a struct's fields
+func fieldNames(t reflect.Type) []string {
+	names := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		if name := fieldName(t.Field(i)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// readOnlyFields are read from other files when the config is
+// loaded, so setting them would be silently overwritten.  The
+// value is the file the field is read from
+var readOnlyFields = map[string]string{
+	"mod.go": DefGoModFile,
+}
+
+// checkWritable returns an error if the field at path can't be changed
+func checkWritable(path string) error {
+	if file, found := readOnlyFields[path]; found {
+		return fmt.Errorf("%w %q: it is read from %s, edit %s instead", ErrInvalidField, path, file, file)
+	}
+	return nil
+}
+
+// fieldRef is a field found by a dotted path.  If the path ends
+// with an index then parent is the slice and index is the element
+type fieldRef struct {
+	value  reflect.Value
+	parent reflect.Value
+	index  int
+}
+
+// lookupField finds the field referred to by a dotted path of JSON
+// names such as maintainer.email or authors[0].name
+func (c *Config) lookupField(path string) (ref fieldRef, err error) {
+	if path == "" {
+		return ref, fmt.Errorf("%w: empty path", ErrUnknownField)
+	}
+
+	ref.value = reflect.ValueOf(c).Elem()
+	ref.index = -1
+	walked := ""
+	for _, segment := range strings.Split(path, ".") {
+		name := segment
+		indexes := ""
+		if i := strings.Index(segment, "["); i >= 0 {
+			name, indexes = segment[:i], segment[i:]
+		}
+
+		if ref.value.Kind() != reflect.Struct {
+			return ref, fmt.Errorf("%w %q: %s does not have any fields", ErrUnknownField, path, walked)
+		}
+
+		found := false
+		for i := 0; i < ref.value.NumField(); i++ {
+			if fieldName(ref.value.Type().Field(i)) == name {
+				ref.value, ref.parent, ref.index = ref.value.Field(i), reflect.Value{}, -1
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return ref, fmt.Errorf("%w %q, expected one of %s", ErrUnknownField, path, strings.Join(fieldNames(ref.value.Type()), ", "))
+		}
+		walked = strings.TrimPrefix(walked+"."+name, ".")
+
+		for indexes != "" {
+			end := strings.Index(indexes, "]")
+			if !strings.HasPrefix(indexes, "[") || end < 0 {
+				return ref, fmt.Errorf("%w %q: malformed index", ErrUnknownField, path)
+			}
+
+			var i int
+			i, err = strconv.Atoi(indexes[1:end])
+			if err != nil || ref.value.Kind() != reflect.Slice {
+				return ref, fmt.Errorf("%w %q: %s can not be indexed with %s", ErrUnknownField, path, walked, indexes[:end+1])
+			} else if i < 0 || i >= ref.value.Len() {
+				return ref, fmt.Errorf("%w %q: %s has %d item(s)", ErrUnknownField, path, walked, ref.value.Len())
+			}
+
+			ref.parent, ref.index, ref.value = ref.value, i, ref.value.Index(i)
+			walked += indexes[:end+1]
+			indexes = indexes[end+1:]
+		}
+	}
+	return ref, nil
+}
+
+// GetField returns the value of the field at path.  Values that
+// have a string form (such as versions) are returned in that form,
+// others are returned as JSON
+func (c *Config) GetField(path string) (string, error) {
+	ref, err := c.lookupField(path)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := ref.value.Addr().Interface().(type) {
+	case *string:
+		return *v, nil
+	case fmt.Stringer:
+		if ref.value.Kind() != reflect.Slice {
+			return v.String(), nil
+		}
+	}
+
+	// the address is marshaled so that pointer receiver
+	// marshalers (such as Version's) are used
+	content, err := json.MarshalIndent(ref.value.Addr().Interface(), "", "  ")
+	return string(content), err
+}
+
+// SetField parses the value and assigns it to the field at path.  Fields
+// with their own syntax (such as versions and durations) are parsed by
+// that type.  Lists of strings are comma separated and values set on a
+// list of authors are appended to it
+func (c *Config) SetField(path, value string) error {
+	ref, err := c.lookupField(path)
+	if err == nil {
+		err = checkWritable(path)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if setter, ok := ref.value.Addr().Interface().(flag.Value); ok {
+		err = setter.Set(value)
+	} else {
+		switch ref.value.Kind() {
+		case reflect.String:
+			ref.value.SetString(value)
+		case reflect.Bool:
+			var b bool
+			if b, err = strconv.ParseBool(value); err == nil {
+				ref.value.SetBool(b)
+			}
+		case reflect.Int, reflect.Int64:
+			var i int64
+			if i, err = strconv.ParseInt(value, 10, 64); err == nil {
+				ref.value.SetInt(i)
+			}
+		case reflect.Slice:
+			if ref.value.Type().Elem().Kind() != reflect.String {
+				return fmt.Errorf("%w %q: set the fields of each item instead (ie %s[0].%s)", ErrInvalidField, path, path, fieldNames(ref.value.Type().Elem())[0])
+			}
+
+			list := []string{}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			ref.value.Set(reflect.ValueOf(list))
+		case reflect.Struct:
+			return fmt.Errorf("%w %q: set one of its fields instead (%s)", ErrInvalidField, path, strings.Join(fieldNames(ref.value.Type()), ", "))
+		default:
+			return fmt.Errorf("%w %q: unsupported type %v", ErrInvalidField, path, ref.value.Type())
+		}
+	}
+
+	if err != nil {
+		err = fmt.Errorf("%w %q: %v", ErrInvalidField, path, err)
+	}
+	return err
+}
+
+// UnsetField resets the field at path to its zero value.  If the
+// path refers to an item in a list then the item is removed
+func (c *Config) UnsetField(path string) error {
+	ref, err := c.lookupField(path)
+	if err == nil {
+		err = checkWritable(path)
+	}
+
+	if err == nil {
+		if ref.parent.IsValid() {
+			removed := reflect.AppendSlice(ref.parent.Slice(0, ref.index), ref.parent.Slice(ref.index+1, ref.parent.Len()))
+			ref.parent.Set(removed)
+		} else {
+			ref.value.Set(reflect.Zero(ref.value.Type()))
+		}
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+package waffle
+
+import (
+	"errors"
+	"testing"
+)
+
+func testFieldConfig(t *testing.T) *Config {
+	t.Helper()
+	return &Config{
+		Name:         "widgets",
+		Desc:         "Widget server",
+		Maintainer:   Maintainer{Name: "Tester", Email: "tester@example.com"},
+		Authors:      Maintainers{{Name: "Alice", Email: "alice@example.com"}, {Name: "Bob"}},
+		Year:         2021,
+		Module:       Module{Path: "example.com/widgets", Version: mustParseVersion(t, "1.2.3"), GoVersion: "1.17"},
+		Environments: []Environment{NewEnvironment(DefEnvironment)},
+		Features:     Features{"docker"},
+	}
+}
+
+func TestGetField(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr error
+	}{
+		{path: "name", want: "widgets"},
+		{path: "maintainer.email", want: "tester@example.com"},
+		{path: "authors[1].name", want: "Bob"},
+		{path: "mod.version", want: "1.2.3"},
+		{path: "year", want: "2021"},
+		{path: "environments[0].readTimeout", want: "15s"},
+		{path: "environments[0].listenOn", want: "[\n  \":8080\"\n]"},
+		{path: "environments[0].listenOn[0]", want: ":8080"},
+		{path: "features", want: "[\n  \"docker\"\n]"},
+
+		{path: "", wantErr: ErrUnknownField},
+		{path: "nope", wantErr: ErrUnknownField},
+		{path: "maintainer.nope", wantErr: ErrUnknownField},
+		{path: "name.first", wantErr: ErrUnknownField},
+		{path: "name[0]", wantErr: ErrUnknownField},
+		{path: "authors[2]", wantErr: ErrUnknownField},
+		{path: "authors[-1]", wantErr: ErrUnknownField},
+		{path: "authors[x]", wantErr: ErrUnknownField},
+		{path: "authors[0", wantErr: ErrUnknownField},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			got, err := testFieldConfig(t).GetField(test.path)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Wanted error %v got %v", test.wantErr, err)
+			}
+
+			if got != test.want {
+				t.Errorf("Wanted %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSetField(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		value   string
+		want    string
+		wantErr error
+	}{
+		{name: "string", path: "name", value: "gadgets", want: "gadgets"},
+		{name: "nested", path: "maintainer.email", value: "new@example.com", want: "new@example.com"},
+		{name: "indexed", path: "authors[1].email", value: "bob@example.com", want: "bob@example.com"},
+		{name: "int", path: "year", value: "2022", want: "2022"},
+		{name: "version", path: "mod.version", value: "2.0.0", want: "2.0.0"},
+		{name: "duration", path: "environments[0].readTimeout", value: "1m", want: "1m0s"},
+		{name: "string list", path: "environments[0].listenOn", value: ":80, ,:443", want: "[\n  \":80\",\n  \":443\"\n]"},
+		{name: "features replaced", path: "features", value: "hooks", want: "[\n  \"hooks\"\n]"},
+		{name: "features deduplicated", path: "features", value: "hooks, docker,hooks", want: "[\n  \"hooks\",\n  \"docker\"\n]"},
+		{name: "features cleared", path: "features", value: "", want: "[]"},
+
+		{name: "invalid int", path: "year", value: "soon", want: "2021", wantErr: ErrInvalidField},
+		{name: "invalid version", path: "mod.version", value: "2.0", want: "1.2.3", wantErr: ErrInvalidField},
+		{name: "invalid duration", path: "environments[0].readTimeout", value: "soon", want: "15s", wantErr: ErrInvalidField},
+		{name: "invalid feature", path: "features", value: "hooks,bogus", want: "[\n  \"docker\"\n]", wantErr: ErrInvalidField},
+		{name: "struct", path: "maintainer", value: "x", wantErr: ErrInvalidField},
+		{name: "list of structs", path: "environments", value: "x", wantErr: ErrInvalidField},
+		{name: "read only", path: "mod.go", value: "1.18", want: "1.17", wantErr: ErrInvalidField},
+		{name: "unknown", path: "mod.nope", value: "x", wantErr: ErrUnknownField},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := testFieldConfig(t)
+			err := c.SetField(test.path, test.value)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Wanted error %v got %v", test.wantErr, err)
+			}
+
+			if test.want == "" {
+				return
+			}
+
+			if got, err := c.GetField(test.path); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			} else if got != test.want {
+				t.Errorf("Wanted %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestUnsetField(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		check   string
+		want    string
+		wantErr error
+	}{
+		{name: "string", path: "desc", check: "desc", want: ""},
+		{name: "nested", path: "maintainer.email", check: "maintainer.email", want: ""},
+		{name: "version", path: "mod.version", check: "mod.version", want: "0.0.0"},
+		{name: "list", path: "features", check: "features", want: "null"},
+		{name: "list item", path: "authors[0]", check: "authors[0].name", want: "Bob"},
+		{name: "last list item", path: "authors[1]", check: "authors[0].name", want: "Alice"},
+		{name: "read only", path: "mod.go", check: "mod.go", want: "1.17", wantErr: ErrInvalidField},
+		{name: "unknown", path: "nope", check: "name", want: "widgets", wantErr: ErrUnknownField},
+		{name: "out of range", path: "authors[2]", check: "authors[1].name", want: "Bob", wantErr: ErrUnknownField},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := testFieldConfig(t)
+			err := c.UnsetField(test.path)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Wanted error %v got %v", test.wantErr, err)
+			}
+
+			if got, err := c.GetField(test.check); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			} else if got != test.want {
+				t.Errorf("Wanted %q got %q", test.want, got)
+			}
+		})
+	}
+}
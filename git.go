@@ -2,47 +2,134 @@ package waffle
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/go-git/go-git/v5"
 	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 const (
+	// DefBranch is the name of the initial branch if
+	// init.defaultBranch isn't set in the git config
+	DefBranch = "main"
+
 	ErrNoGitRepo     = WaffleError("Git repo does not exist")
 	ErrNoGitVersion  = WaffleError("Git repo does not have any semantic version tags")
 	ErrInvalidGitURL = WaffleError("Git remote URL can't be parsed")
 	ErrNoRepoName    = WaffleError("could not determine repo name")
 	ErrOriginExists  = WaffleError("Remote 'origin' already exists")
+	ErrNoOrigin      = WaffleError("Remote 'origin' does not exist")
+	ErrDirtyWorktree = WaffleError("Git worktree has uncommitted changes")
+	ErrStagedChanges = WaffleError("Git index has staged changes that would be included in the commit")
+	ErrNoChanges     = WaffleError("there are no changes to commit")
 )
 
-func LoadGitMaintainer() (Maintainer, error) {
-	m := Maintainer{}
-	git, err := gitconfig.LoadConfig(gitconfig.GlobalScope)
-	if err == nil {
-		m.Name = git.User.Name
-		m.Email = git.User.Email
+// LoadGitMaintainer determines the maintainer's identity the same way
+// git determines the commit author.  The name and email are read from
+// the config of the repo containing dir, then the global and system git
+// config, and then the GIT_AUTHOR_NAME, GIT_AUTHOR_EMAIL and EMAIL
+// environment variables.  The first place that sets each of them wins.
+// Fields that aren't found are left empty so they can be prompted for.
+// If a config file can't be read the rest are still checked and the
+// first error is returned with whatever was found
+func LoadGitMaintainer(dir string) (m Maintainer, err error) {
+	configs := []*gitconfig.Config{}
+	if repo, err1 := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true}); err1 == nil {
+		var cfg *gitconfig.Config
+		if cfg, err = repo.Config(); err == nil {
+			configs = append(configs, cfg)
+		}
+	}
+
+	for _, scope := range []gitconfig.Scope{gitconfig.GlobalScope, gitconfig.SystemScope} {
+		cfg, err1 := gitconfig.LoadConfig(scope)
+		if err1 == nil {
+			configs = append(configs, cfg)
+		} else if err == nil {
+			err = err1
+		}
+	}
+
+	set := func(field *string, values ...string) {
+		for _, value := range values {
+			if *field == "" {
+				*field = value
+			}
+		}
+	}
+
+	for _, cfg := range configs {
+		set(&m.Name, cfg.Author.Name, cfg.User.Name)
+		set(&m.Email, cfg.Author.Email, cfg.User.Email)
 	}
+
+	set(&m.Name, os.Getenv("GIT_AUTHOR_NAME"))
+	set(&m.Email, os.Getenv("GIT_AUTHOR_EMAIL"), os.Getenv("EMAIL"))
 	return m, err
 }
 
 type GitRepo struct {
 	repo *git.Repository
+
+	// prefix is prepended to release tag names when the project
+	// is in a sub directory of the repo (ie "api/" for api/v1.2.0)
+	prefix string
+
+	// signKey signs new tags if it is set
+	signKey SigningKey
 }
 
+// OpenGit opens the git repo that contains dir.  If dir is a sub
+// directory of the repo then release tags are expected to be prefixed
+// with the path to dir, the same as go module tags
 func OpenGit(dir string) (gr *GitRepo, err error) {
-	repo, err := git.PlainOpen(dir)
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
 	if err == nil {
 		gr = &GitRepo{repo: repo}
+		var wt *git.Worktree
+		if wt, err = repo.Worktree(); err == nil {
+			var path string
+			path, err = worktreePath(wt, dir)
+			if err == nil && path != "." {
+				gr.prefix = path + "/"
+			}
+		}
 	} else if errors.Is(err, git.ErrRepositoryNotExists) {
 		err = ErrNoGitRepo
 	}
 	return
 }
 
-func InitGit(dir string) (gr *GitRepo, err error) {
+// DefaultBranch returns init.defaultBranch from the global or
+// system git config, or DefBranch if it isn't set
+func DefaultBranch() string {
+	for _, scope := range []gitconfig.Scope{gitconfig.GlobalScope, gitconfig.SystemScope} {
+		if cfg, err := gitconfig.LoadConfig(scope); err == nil && cfg.Init.DefaultBranch != "" {
+			return cfg.Init.DefaultBranch
+		}
+	}
+	return DefBranch
+}
+
+// InitGit creates an empty git repo in dir with HEAD pointing
+// at branch
+func InitGit(dir, branch string) (gr *GitRepo, err error) {
 	repo, err := git.PlainInit(dir, false)
+	if err == nil {
+		head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(branch))
+		err = repo.Storer.SetReference(head)
+	}
+
 	if err == nil {
 		gr = &GitRepo{repo: repo}
 	}
@@ -72,37 +159,451 @@ func (gr *GitRepo) SetOrigin(url string) error {
 		} else {
 			_, err = gr.repo.CreateRemote(&gitconfig.RemoteConfig{
 				Name: "origin",
-				URLs: []string{"url"},
+				URLs: []string{url},
 			})
 		}
 	}
 	return err
 }
 
-func (gr *GitRepo) Versions() (versions []Version, err error) {
-	tags, err := gr.repo.TagObjects()
+// GitURL is the location of a repo on a git server
+type GitURL struct {
+	// Host is the name of the server without the user or port
+	Host string
+	// Path is the path to the repo on the server without the
+	// leading slash or the .git suffix (ie org/repo)
+	Path string
+}
+
+// ParseGitURL parses the remote URL forms that git accepts for
+// servers: https://host/org/repo.git, ssh://user@host:port/org/repo.git
+// and the scp-like user@host:org/repo.git.  Local paths are not
+// accepted since they don't identify a server
+func ParseGitURL(str string) (gu GitURL, err error) {
+	if strings.Contains(str, "://") {
+		var u *url.URL
+		if u, err = url.Parse(str); err == nil {
+			gu.Host, gu.Path = u.Hostname(), u.Path
+		}
+	} else if i := strings.Index(str, ":"); i > 0 && !strings.Contains(str[:i], "/") {
+		// git treats a colon before the first slash as scp-like syntax
+		gu.Host, gu.Path = str[:i], str[i+1:]
+		gu.Host = gu.Host[strings.LastIndex(gu.Host, "@")+1:]
+	}
+
+	gu.Host = strings.ToLower(gu.Host)
+	gu.Path = strings.TrimSuffix(strings.Trim(gu.Path, "/"), ".git")
+	if err != nil || gu.Host == "" {
+		return GitURL{}, fmt.Errorf("%w: %q", ErrInvalidGitURL, str)
+	} else if gu.Name() == "" {
+		return GitURL{}, fmt.Errorf("%w from %q", ErrNoRepoName, str)
+	}
+	return gu, nil
+}
+
+// Name is the name of the repo (ie repo for github.com/org/repo)
+func (gu GitURL) Name() string {
+	if gu.Path == "" {
+		return ""
+	}
+	return path.Base(gu.Path)
+}
+
+// ModulePath is the go module path for the repo (ie github.com/org/repo)
+func (gu GitURL) ModulePath() string {
+	return gu.Host + "/" + gu.Path
+}
+
+// WebURL is the URL of the repo's webpage (ie https://github.com/org/repo)
+func (gu GitURL) WebURL() string {
+	return "https://" + gu.ModulePath()
+}
+
+// Origin returns the parsed URL of the "origin" remote
+func (gr *GitRepo) Origin() (GitURL, error) {
+	remotes, err := gr.Remotes()
 	if err == nil {
-		err = tags.ForEach(func(t *object.Tag) error {
-			version := Version{}
-			err = version.Set(t.Name)
-			if err == nil {
-				versions = append(versions, version)
+		if urls := remotes["origin"]; len(urls) > 0 {
+			return ParseGitURL(urls[0])
+		}
+		err = ErrNoOrigin
+	}
+	return GitURL{}, err
+}
+
+// DefaultsFromOrigin fills in the project name, webpage and
+// module path from the repo's origin URL if they haven't been set.
+// If the project is in a sub directory of the repo then the sub
+// directory is appended to the module path
+func (c *Config) DefaultsFromOrigin(gr *GitRepo) error {
+	gu, err := gr.Origin()
+	if err == nil {
+		modPath := gu.ModulePath()
+		if gr.prefix != "" {
+			modPath = path.Join(modPath, gr.prefix)
+		}
+
+		if c.Module.Path == "" {
+			c.Module.Path = modPath
+		}
+
+		if c.Name == "" {
+			c.Name = path.Base(modPath)
+		}
+
+		if c.URL == "" {
+			c.URL = gu.WebURL()
+		}
+	}
+	return err
+}
+
+// Release is a semantic version tag and the commit it points to
+type Release struct {
+	// Version is the semantic version parsed from the tag name
+	Version Version
+	// Tag is the name of the git tag
+	Tag string
+	// Commit is the commit that was tagged
+	Commit *object.Commit
+}
+
+// TagName returns the name of the release tag for a version
+func (gr *GitRepo) TagName(version Version) string {
+	return gr.prefix + version.Tag()
+}
+
+// tagCommit resolves a tag, either annotated or lightweight, to the
+// commit it points to.  Nil is returned if the tag doesn't point
+// to a commit
+func (gr *GitRepo) tagCommit(hash plumbing.Hash) (*object.Commit, error) {
+	tag, err := gr.repo.TagObject(hash)
+	if err == nil {
+		var commit *object.Commit
+		commit, err = tag.Commit()
+		if err == object.ErrUnsupportedObject {
+			return nil, nil
+		}
+		return commit, err
+	} else if err != plumbing.ErrObjectNotFound {
+		return nil, err
+	}
+
+	commit, err := gr.repo.CommitObject(hash)
+	if err == plumbing.ErrObjectNotFound {
+		return nil, nil
+	}
+	return commit, err
+}
+
+// Releases returns every tag in the repo that is named with a semantic
+// version, sorted from lowest to highest precedence.  Both annotated and
+// lightweight tags are included and the version may have a "v" prefix.  If
+// the project is in a sub directory of the repo then only tags with the
+// sub directory prefix (ie api/v1.2.0) are included
+func (gr *GitRepo) Releases() (releases []Release, err error) {
+	tags, err := gr.repo.Tags()
+	if err == nil {
+		err = tags.ForEach(func(ref *plumbing.Reference) error {
+			name := ref.Name().Short()
+			if !strings.HasPrefix(name, gr.prefix) {
+				return nil
+			}
+
+			release := Release{Tag: name}
+			if release.Version.Set(strings.TrimPrefix(name, gr.prefix)) == nil {
+				commit, err := gr.tagCommit(ref.Hash())
+				if err != nil {
+					return err
+				} else if commit != nil {
+					release.Commit = commit
+					releases = append(releases, release)
+				}
 			}
 			return nil
 		})
 	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Version.Compare(releases[j].Version) < 0
+	})
 	return
 }
 
+func (gr *GitRepo) Versions() (versions []Version, err error) {
+	releases, err := gr.Releases()
+	for _, release := range releases {
+		versions = append(versions, release.Version)
+	}
+	return
+}
+
+// CurrentVersion is the version of the nearest release reachable
+// from HEAD.  Releases on other branches are ignored
 func (gr *GitRepo) CurrentVersion() (version Version, err error) {
-	versions, err := gr.Versions()
+	d, err := gr.Describe()
 	if err == nil {
-		if len(versions) > 0 {
-			sort.Sort(VersionList(versions))
-			version = versions[len(versions)-1]
+		if d.Release != nil {
+			version = d.Release.Version
 		} else {
 			err = ErrNoGitVersion
 		}
 	}
 	return
 }
+
+// IsEmpty returns true if the repo doesn't have any commits yet
+func (gr *GitRepo) IsEmpty() (bool, error) {
+	_, err := gr.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return true, nil
+	}
+	return false, err
+}
+
+// FirstCommitYear returns the year of the oldest commit reachable from
+// HEAD.  Zero is returned if there are no commits
+func (gr *GitRepo) FirstCommitYear() (year int, err error) {
+	head, err := gr.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return 0, nil
+	}
+
+	var commit *object.Commit
+	if err == nil {
+		commit, err = gr.repo.CommitObject(head.Hash())
+	}
+
+	if err == nil {
+		first := commit.Author.When
+		err = object.NewCommitPreorderIter(commit, nil, nil).ForEach(func(c *object.Commit) error {
+			if c.Author.When.Before(first) {
+				first = c.Author.When
+			}
+			return nil
+		})
+		year = first.Year()
+	}
+	return
+}
+
+// Branch returns the name of the branch HEAD points to
+func (gr *GitRepo) Branch() (string, error) {
+	head, err := gr.repo.Reference(plumbing.HEAD, false)
+	if err == nil {
+		return head.Target().Short(), nil
+	}
+	return "", err
+}
+
+// IsClean returns true if the worktree has no staged, modified
+// or untracked files
+func (gr *GitRepo) IsClean() (clean bool, err error) {
+	wt, err := gr.repo.Worktree()
+	if err == nil {
+		var status git.Status
+		status, err = wt.Status()
+		if err == nil {
+			clean = status.IsClean()
+		}
+	}
+	return
+}
+
+// worktreePath converts a filename, relative to the current
+// directory, to a path relative to the root of the worktree
+func worktreePath(wt *git.Worktree, filename string) (string, error) {
+	abs, err := filepath.Abs(filename)
+	if err == nil {
+		var root string
+		root, err = filepath.Abs(wt.Filesystem.Root())
+		if err == nil {
+			filename, err = filepath.Rel(root, abs)
+		}
+	}
+	return filepath.ToSlash(filename), err
+}
+
+// HasStagedChanges returns true if the index has changes that
+// would be included in the next commit
+func (gr *GitRepo) HasStagedChanges() (staged bool, err error) {
+	wt, err := gr.repo.Worktree()
+	if err == nil {
+		var status git.Status
+		status, err = wt.Status()
+		for _, s := range status {
+			staged = staged || s.Staging != git.Unmodified && s.Staging != git.Untracked
+		}
+	}
+	return
+}
+
+// Commit stages the given files and commits them with the supplied
+// message.  Files that have been removed are removed from the index
+// and files without changes are skipped.  Other files that are
+// already staged are not committed.  ErrNoChanges is returned if
+// none of the files have changed.  The author is read from the git
+// config
+func (gr *GitRepo) Commit(msg string, files ...string) (hash plumbing.Hash, err error) {
+	wt, err := gr.repo.Worktree()
+	paths := []string{}
+	for i := 0; err == nil && i < len(files); i++ {
+		var path string
+		if path, err = worktreePath(wt, files[i]); err == nil {
+			paths = append(paths, path)
+		}
+	}
+
+	if err == nil {
+		hash, err = gr.commit(wt, &git.CommitOptions{}, msg, paths)
+	}
+	return
+}
+
+// InitialCommit commits every file in the worktree that isn't
+// ignored, authored by the given maintainer
+func (gr *GitRepo) InitialCommit(msg string, author Maintainer) (hash plumbing.Hash, err error) {
+	wt, err := gr.repo.Worktree()
+	var status git.Status
+	if err == nil {
+		status, err = wt.Status()
+	}
+
+	if err == nil {
+		paths := []string{}
+		for path := range status {
+			paths = append(paths, path)
+		}
+		hash, err = gr.commit(wt, &git.CommitOptions{Author: author.signature()}, msg, paths)
+	}
+	return
+}
+
+// commit stages the paths, which are relative to the worktree
+// root, and commits them.  Only the paths are committed, anything
+// else that was already staged is still staged afterwards
+func (gr *GitRepo) commit(wt *git.Worktree, opts *git.CommitOptions, msg string, paths []string) (hash plumbing.Hash, err error) {
+	status, err := wt.Status()
+	changed := 0
+	for i := 0; err == nil && i < len(paths); i++ {
+		if s, found := status[paths[i]]; found && s.Worktree != git.Unmodified {
+			// Add also stages removed files
+			_, err = wt.Add(paths[i])
+			changed++
+		} else if found && s.Staging != git.Unmodified {
+			changed++
+		}
+	}
+
+	if err == nil && changed == 0 {
+		err = ErrNoChanges
+	}
+
+	var restage func() error
+	if err == nil {
+		restage, err = gr.unstageOthers(status, paths)
+	}
+
+	if err == nil {
+		hash, err = wt.Commit(msg, opts)
+		if err1 := restage(); err == nil {
+			err = err1
+		}
+	}
+	return
+}
+
+// unstageOthers resets the index entries of staged files that aren't
+// in paths to their HEAD version, so that a commit only includes the
+// paths.  The returned function stages the other files again
+func (gr *GitRepo) unstageOthers(status git.Status, paths []string) (restage func() error, err error) {
+	committed := make(map[string]bool)
+	for _, path := range paths {
+		committed[path] = true
+	}
+
+	var tree *object.Tree
+	head, err := gr.repo.Head()
+	if err == nil {
+		var commit *object.Commit
+		if commit, err = gr.repo.CommitObject(head.Hash()); err == nil {
+			tree, err = commit.Tree()
+		}
+	} else if err == plumbing.ErrReferenceNotFound {
+		// nothing is committed yet
+		err = nil
+	}
+
+	var idx *index.Index
+	if err == nil {
+		idx, err = gr.repo.Storer.Index()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// staged is the staged entry of each file, or nil if
+	// the file's removal was staged
+	staged := make(map[string]*index.Entry)
+	for path, s := range status {
+		if committed[path] || s.Staging == git.Unmodified || s.Staging == git.Untracked {
+			continue
+		}
+
+		staged[path], _ = idx.Remove(path)
+		if tree != nil {
+			if file, err := tree.File(path); err == nil {
+				entry := idx.Add(path)
+				entry.Hash, entry.Mode = file.Hash, file.Mode
+			}
+		}
+	}
+
+	restage = func() error { return nil }
+	if len(staged) > 0 {
+		err = gr.repo.Storer.SetIndex(idx)
+		restage = func() error {
+			idx, err := gr.repo.Storer.Index()
+			if err == nil {
+				for path, entry := range staged {
+					idx.Remove(path)
+					if entry != nil {
+						idx.Entries = append(idx.Entries, entry)
+					}
+				}
+				err = gr.repo.Storer.SetIndex(idx)
+			}
+			return err
+		}
+	}
+	return restage, err
+}
+
+// signature is the git identity of the maintainer
+func (m Maintainer) signature() *object.Signature {
+	return &object.Signature{Name: m.Name, Email: m.Email, When: time.Now()}
+}
+
+// Tag creates an annotated tag pointing at HEAD.  The tagger is
+// read from the git config.  The tag is signed if a signing key
+// has been set
+func (gr *GitRepo) Tag(name, msg string) error {
+	return gr.tag(name, &git.CreateTagOptions{Message: msg})
+}
+
+// TagAs creates an annotated tag pointing at HEAD
+// with the maintainer as the tagger
+func (gr *GitRepo) TagAs(name, msg string, tagger Maintainer) error {
+	return gr.tag(name, &git.CreateTagOptions{Message: msg, Tagger: tagger.signature()})
+}
+
+func (gr *GitRepo) tag(name string, opts *git.CreateTagOptions) error {
+	head, err := gr.repo.Head()
+	if err == nil && gr.signKey != nil {
+		err = gr.signedTag(name, head.Hash(), opts)
+	} else if err == nil {
+		_, err = gr.repo.CreateTag(name, head.Hash(), opts)
+	}
+	return err
+}
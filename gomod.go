@@ -0,0 +1,223 @@
+package waffle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	// DefGoSumFile is the filename of the go checksum file
+	DefGoSumFile = "go.sum"
+
+	// DefGoVersion is the go version written to go.mod when
+	// the version of the running toolchain can't be determined
+	DefGoVersion = "1.17"
+)
+
+// Requirement is a module that the generated code imports
+type Requirement struct {
+	Path    string
+	Version string
+
+	// Sum and ModSum are the go.sum hashes of the module and its
+	// go.mod file.  These are included so that go.sum can be written
+	// without downloading anything
+	Sum    string
+	ModSum string
+}
+
+// Requirements are the modules that generated code depends on
+var Requirements = []Requirement{
+	{
+		Path:    "github.com/gorilla/mux",
+		Version: "v1.8.0",
+		Sum:     "h1:i40aqfkR1h2SlN9hojwV5ZA91wcXFOvkdNIeFDP5koI=",
+		ModSum:  "h1:DVbg23sWSpFRCP0SfiEN6jmj59UnW/n46BH5rLB71So=",
+	},
+}
+
+// GoMod is the information waffle needs from a go.mod file
+type GoMod struct {
+	// Module is the module path
+	Module string
+
+	// Go is the version from the go directive
+	Go string
+
+	// Require maps the path of each required module to its version
+	Require map[string]string
+}
+
+// ReadGoMod parses the module, go and require directives of a go.mod file
+func ReadGoMod(filename string) (*GoMod, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	mod := &GoMod{Require: make(map[string]string)}
+	inRequire := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		for i := range fields {
+			fields[i] = strings.Trim(fields[i], `"`+"`")
+		}
+
+		switch {
+		case len(fields) == 0:
+		case inRequire && fields[0] == ")":
+			inRequire = false
+		case inRequire && len(fields) >= 2:
+			mod.Require[fields[0]] = fields[1]
+		case fields[0] == "module" && len(fields) >= 2:
+			mod.Module = fields[1]
+		case fields[0] == "go" && len(fields) >= 2:
+			mod.Go = fields[1]
+		case fields[0] == "require" && len(fields) == 2 && fields[1] == "(":
+			inRequire = true
+		case fields[0] == "require" && len(fields) >= 3:
+			mod.Require[fields[1]] = fields[2]
+		}
+	}
+
+	if err = scanner.Err(); err == nil && mod.Module == "" {
+		err = fmt.Errorf("%s does not have a module directive", filename)
+	}
+	return mod, err
+}
+
+// readModulePath returns the path from the module
+// directive of a go.mod file
+func readModulePath(filename string) (string, error) {
+	mod, err := ReadGoMod(filename)
+	if err != nil {
+		return "", err
+	}
+	return mod.Module, nil
+}
+
+// defaultGoVersion is the major and minor version of
+// the go toolchain waffle was built with
+func defaultGoVersion() string {
+	version := strings.TrimPrefix(runtime.Version(), "go")
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 || !strings.HasPrefix(runtime.Version(), "go") {
+		return DefGoVersion
+	}
+	return parts[0] + "." + strings.TrimRightFunc(parts[1], func(r rune) bool { return r < '0' || '9' < r })
+}
+
+// loadGoMod reads the module information from the go.mod file in dir.
+// The go version always comes from go.mod.  The module path is only
+// filled in if the config doesn't have one, a path that doesn't match
+// go.mod is reported when the config is validated
+func (c *Config) loadGoMod(dir string) {
+	filename := filepath.Join(dir, DefGoModFile)
+	mod, err := ReadGoMod(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	} else if err != nil {
+		Logger.Logf("<warn>Warning</warn>: failed to read %s: %v", filename, err)
+		return
+	}
+
+	if c.Module.Path == "" {
+		c.Module.Path = mod.Module
+	}
+	c.Module.GoVersion = mod.Go
+}
+
+// SyncGoMod makes sure the go.mod file in dir requires the modules
+// that generated code depends on.  If go.mod doesn't exist it is
+// created.  Missing requirements are added to both go.mod and
+// go.sum, requirements that are already present are left alone so
+// that they can be upgraded independently of waffle
+func (c *Config) SyncGoMod(dir string) error {
+	filename := filepath.Join(dir, DefGoModFile)
+	mod, err := ReadGoMod(filename)
+	created := errors.Is(err, fs.ErrNotExist)
+	if created {
+		if c.Module.GoVersion == "" {
+			c.Module.GoVersion = defaultGoVersion()
+		}
+		mod = &GoMod{Module: c.Module.Path, Go: c.Module.GoVersion, Require: map[string]string{}}
+		err = nil
+	} else if err != nil {
+		return fmt.Errorf("Failed to read %q: %w", filename, err)
+	}
+
+	missing := []Requirement{}
+	for _, req := range Requirements {
+		if _, found := mod.Require[req.Path]; !found {
+			missing = append(missing, req)
+		}
+	}
+
+	if len(missing) > 0 || created {
+		var content []byte
+		if created {
+			content = []byte(fmt.Sprintf("module %s\n\ngo %s\n", mod.Module, mod.Go))
+		} else {
+			content, err = ioutil.ReadFile(filename)
+		}
+
+		if err == nil {
+			buf := bytes.NewBuffer(content)
+			for _, req := range missing {
+				fmt.Fprintf(buf, "\nrequire %s %s\n", req.Path, req.Version)
+			}
+			err = WriteFile(filename, buf.Bytes())
+		}
+
+		if err == nil {
+			err = writeGoSum(filepath.Join(dir, DefGoSumFile), missing)
+		}
+
+		if err == nil {
+			Logger.Logf("<success>%s</success>", DefGoModFile)
+		}
+	}
+	return err
+}
+
+// writeGoSum adds the hashes of the requirements to the go.sum file
+func writeGoSum(filename string, reqs []Requirement) error {
+	content, err := ioutil.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+
+	if err != nil || len(reqs) == 0 {
+		return err
+	}
+
+	buf := bytes.NewBuffer(content)
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		buf.WriteString("\n")
+	}
+
+	for _, req := range reqs {
+		for _, line := range []string{
+			fmt.Sprintf("%s %s %s\n", req.Path, req.Version, req.Sum),
+			fmt.Sprintf("%s %s/go.mod %s\n", req.Path, req.Version, req.ModSum),
+		} {
+			if !bytes.Contains(content, []byte(line)) {
+				buf.WriteString(line)
+			}
+		}
+	}
+	return WriteFile(filename, buf.Bytes())
+}
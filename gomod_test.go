@@ -0,0 +1,186 @@
+package waffle
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadGoMod(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *GoMod
+		wantErr bool
+	}{
+		{
+			name:    "module only",
+			content: "module example.com/widgets\n",
+			want:    &GoMod{Module: "example.com/widgets", Require: map[string]string{}},
+		},
+		{
+			name:    "require block",
+			content: "module example.com/widgets\n\ngo 1.17\n\nrequire (\n\tgithub.com/gorilla/mux v1.8.0\n\tgolang.org/x/sys v0.1.0 // indirect\n)\n",
+			want:    &GoMod{Module: "example.com/widgets", Go: "1.17", Require: map[string]string{"github.com/gorilla/mux": "v1.8.0", "golang.org/x/sys": "v0.1.0"}},
+		},
+		{
+			name:    "single requires",
+			content: "module example.com/widgets\ngo 1.16\nrequire github.com/gorilla/mux v1.7.0\nrequire golang.org/x/sys v0.1.0 // indirect\n",
+			want:    &GoMod{Module: "example.com/widgets", Go: "1.16", Require: map[string]string{"github.com/gorilla/mux": "v1.7.0", "golang.org/x/sys": "v0.1.0"}},
+		},
+		{
+			name:    "quoted and commented",
+			content: "// the widgets module\nmodule \"example.com/widgets\" // comment\n\nreplace example.com/other => ../other\n",
+			want:    &GoMod{Module: "example.com/widgets", Require: map[string]string{}},
+		},
+		{
+			name:    "no module directive",
+			content: "go 1.17\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), DefGoModFile)
+			writeTestFile(t, filename, test.content)
+			got, err := ReadGoMod(filename)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("Wanted %+v got %+v", test.want, got)
+			}
+		})
+	}
+
+	if _, err := ReadGoMod(filepath.Join(t.TempDir(), DefGoModFile)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Wanted %v got %v", fs.ErrNotExist, err)
+	}
+}
+
+func TestSyncGoMod(t *testing.T) {
+	req := Requirements[0]
+	require := "\nrequire " + req.Path + " " + req.Version + "\n"
+	sums := req.Path + " " + req.Version + " " + req.Sum + "\n" + req.Path + " " + req.Version + "/go.mod " + req.ModSum + "\n"
+
+	tests := []struct {
+		name      string
+		goMod     *string
+		goSum     *string
+		goVersion string
+		wantMod   string
+		wantSum   string
+	}{
+		{
+			name:      "created",
+			goVersion: "1.16",
+			wantMod:   "module example.com/widgets\n\ngo 1.16\n" + require,
+			wantSum:   sums,
+		},
+		{
+			name:    "requirement added",
+			goMod:   strPtr("module example.com/widgets\n\ngo 1.17\n"),
+			goSum:   strPtr("example.com/other v1.0.0 h1:x="),
+			wantMod: "module example.com/widgets\n\ngo 1.17\n" + require,
+			wantSum: "example.com/other v1.0.0 h1:x=\n" + sums,
+		},
+		{
+			name:    "go.sum lines not repeated",
+			goMod:   strPtr("module example.com/widgets\n"),
+			goSum:   strPtr(sums),
+			wantMod: "module example.com/widgets\n" + require,
+			wantSum: sums,
+		},
+		{
+			name:    "other version kept",
+			goMod:   strPtr("module example.com/widgets\n\nrequire " + req.Path + " v1.7.4\n"),
+			wantMod: "module example.com/widgets\n\nrequire " + req.Path + " v1.7.4\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if test.goMod != nil {
+				writeTestFile(t, filepath.Join(dir, DefGoModFile), *test.goMod)
+			}
+
+			if test.goSum != nil {
+				writeTestFile(t, filepath.Join(dir, DefGoSumFile), *test.goSum)
+			}
+
+			c := &Config{Module: Module{Path: "example.com/widgets", GoVersion: test.goVersion}}
+			if err := c.SyncGoMod(dir); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got := readTestFile(t, filepath.Join(dir, DefGoModFile)); got != test.wantMod {
+				t.Errorf("Wanted go.mod %q got %q", test.wantMod, got)
+			}
+
+			if test.wantSum == "" && test.goSum == nil {
+				return
+			}
+
+			if got := readTestFile(t, filepath.Join(dir, DefGoSumFile)); got != test.wantSum {
+				t.Errorf("Wanted go.sum %q got %q", test.wantSum, got)
+			}
+		})
+	}
+}
+
+func TestSyncGoModDefaultVersion(t *testing.T) {
+	dir := t.TempDir()
+	c := &Config{Module: Module{Path: "example.com/widgets"}}
+	if err := c.SyncGoMod(dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if c.Module.GoVersion == "" || !strings.Contains(readTestFile(t, filepath.Join(dir, DefGoModFile)), "\ngo "+c.Module.GoVersion+"\n") {
+		t.Errorf("Expected go.mod to have the go version %q", c.Module.GoVersion)
+	}
+}
+
+func TestLoadGoMod(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		goMod    string
+		wantPath string
+		wantGo   string
+	}{
+		{"path from go.mod", "", "module example.com/widgets\n\ngo 1.16\n", "example.com/widgets", "1.16"},
+		{"configured path kept", "example.com/gadgets", "module example.com/widgets\n\ngo 1.16\n", "example.com/gadgets", "1.16"},
+		{"no go.mod", "example.com/gadgets", "", "example.com/gadgets", ""},
+		{"invalid go.mod", "example.com/gadgets", "go 1.16\n", "example.com/gadgets", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if test.goMod != "" {
+				writeTestFile(t, filepath.Join(dir, DefGoModFile), test.goMod)
+			}
+
+			c := &Config{Module: Module{Path: test.path}}
+			c.loadGoMod(dir)
+			if c.Module.Path != test.wantPath {
+				t.Errorf("Wanted path %q got %q", test.wantPath, c.Module.Path)
+			}
+
+			if c.Module.GoVersion != test.wantGo {
+				t.Errorf("Wanted go %q got %q", test.wantGo, c.Module.GoVersion)
+			}
+		})
+	}
+}
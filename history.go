@@ -0,0 +1,446 @@
+package waffle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefHistoryDir is where the history of changed files is kept
+	// relative to the project root
+	DefHistoryDir = ".waffle/history"
+
+	// MaxHistory is the number of commands that are kept in the history
+	MaxHistory = 20
+
+	ErrNoHistory = WaffleError("there is nothing to undo")
+	ErrOutOfDate = WaffleError("files are out of date")
+
+	manifestFile = "manifest.json"
+)
+
+// history is the journal that writes are recorded in.  If history
+// is nil then writes are still atomic, but are not recorded
+var history *History
+
+// checking is set while CheckWrites is running.  Files are compared
+// instead of written and the ones that differ are added to changedFiles
+var checking bool
+var changedFiles []string
+
+type historyFile struct {
+	// Path is relative to the project root
+	Path string `json:"path"`
+	// Existed indicates whether the file existed before the command ran
+	Existed bool `json:"existed"`
+}
+
+// historyEntry is the record of all the files a single command changed
+type historyEntry struct {
+	Command  string        `json:"command"`
+	Time     time.Time     `json:"time"`
+	Complete bool          `json:"complete"`
+	Files    []historyFile `json:"files"`
+
+	dir string
+}
+
+func (he *historyEntry) save() error {
+	content, err := json.MarshalIndent(he, "", "  ")
+	if err == nil {
+		err = atomicWrite(filepath.Join(he.dir, manifestFile), content)
+	}
+	return err
+}
+
+func (he *historyEntry) recorded(path string) bool {
+	for _, file := range he.Files {
+		if file.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// History is a journal of the previous content of every file changed
+// by a command.  The journal allows the last command to be undone and
+// allows changes that were interrupted part way through to be rolled
+// back
+type History struct {
+	root    string
+	dir     string
+	command string
+	entry   *historyEntry
+}
+
+// OpenHistory starts recording the files changed by the given command.  If
+// the previous command was interrupted before it completed then the
+// changes it made are rolled back first
+func OpenHistory(root, command string) (*History, error) {
+	h := &History{
+		root:    root,
+		dir:     filepath.Join(root, filepath.FromSlash(DefHistoryDir)),
+		command: command,
+	}
+
+	entries, err := h.entries()
+	if err == nil && len(entries) > 0 && !entries[len(entries)-1].Complete {
+		last := entries[len(entries)-1]
+		Logger.Logf("<warn>Warning</warn>: rolling back interrupted command %q", last.Command)
+		err = h.revert(last)
+	}
+
+	if err == nil {
+		history = h
+	}
+	return h, err
+}
+
+// Close marks the command as complete so that it can be undone and
+// removes the oldest entries from the history.  Writes are no longer
+// recorded once the history is closed
+func (h *History) Close() error {
+	if history == h {
+		history = nil
+	}
+
+	var err error
+	if h.entry != nil {
+		h.entry.Complete = true
+		err = h.entry.save()
+		h.entry = nil
+	}
+
+	if err == nil {
+		var entries []*historyEntry
+		entries, err = h.entries()
+		for i := 0; err == nil && i < len(entries)-MaxHistory; i++ {
+			err = os.RemoveAll(entries[i].dir)
+		}
+	}
+	return err
+}
+
+// Files returns the name of every file that has been written or
+// removed by the command so far, relative to the current directory
+func (h *History) Files() (files []string) {
+	if h.entry != nil {
+		for _, file := range h.entry.Files {
+			files = append(files, filepath.Join(h.root, filepath.FromSlash(file.Path)))
+		}
+	}
+	return
+}
+
+// entries loads every entry in the history, oldest first
+func (h *History) entries() (entries []*historyEntry, err error) {
+	dirs, err := ioutil.ReadDir(h.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].Name() < dirs[j].Name() })
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		entry := &historyEntry{dir: filepath.Join(h.dir, dir.Name())}
+		var content []byte
+		content, err = ioutil.ReadFile(filepath.Join(entry.dir, manifestFile))
+		if errors.Is(err, fs.ErrNotExist) {
+			// a command that was interrupted before its manifest was
+			// saved hadn't changed anything, so there is nothing to
+			// roll back
+			if err = os.RemoveAll(entry.dir); err != nil {
+				return nil, fmt.Errorf("Failed to remove history %s: %w", entry.dir, err)
+			}
+			continue
+		} else if err == nil {
+			err = json.Unmarshal(content, entry)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read history %s: %w", entry.dir, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// begin creates the history entry for the command
+func (h *History) begin() error {
+	h.entry = &historyEntry{
+		Command: h.command,
+		Time:    time.Now(),
+		dir:     filepath.Join(h.dir, fmt.Sprintf("%020d", time.Now().UnixNano())),
+	}
+
+	err := os.MkdirAll(h.entry.dir, 0755)
+	if err == nil {
+		// keep the history out of the project's git repo
+		err = atomicWrite(filepath.Join(h.dir, ".gitignore"), []byte("*\n"))
+	}
+
+	if err == nil {
+		err = h.entry.save()
+	}
+	return err
+}
+
+// record saves a copy of the file before it is changed for the first
+// time by the current command
+func (h *History) record(filename string) error {
+	abs, err := filepath.Abs(filename)
+	root := ""
+	if err == nil {
+		root, err = filepath.Abs(h.root)
+	}
+
+	var path string
+	if err == nil {
+		path, err = filepath.Rel(root, abs)
+	}
+
+	// files outside of the project (ie an exported document) aren't
+	// part of the project, so they aren't journaled
+	outside := path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator))
+	if err != nil || outside || h.entry != nil && h.entry.recorded(filepath.ToSlash(path)) {
+		return err
+	}
+
+	if h.entry == nil {
+		if err = h.begin(); err != nil {
+			return err
+		}
+	}
+
+	file := historyFile{Path: filepath.ToSlash(path), Existed: true}
+	content, err := ioutil.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		file.Existed = false
+		err = nil
+	} else if err == nil {
+		backup := filepath.Join(h.entry.dir, "files", path)
+		err = os.MkdirAll(filepath.Dir(backup), 0755)
+		if err == nil {
+			err = atomicWrite(backup, content)
+		}
+	}
+
+	if err == nil {
+		h.entry.Files = append(h.entry.Files, file)
+		err = h.entry.save()
+	}
+	return err
+}
+
+// revert restores every file in the entry to its previous
+// content and then removes the entry from the history
+func (h *History) revert(entry *historyEntry) (err error) {
+	for i := len(entry.Files) - 1; err == nil && i >= 0; i-- {
+		file := entry.Files[i]
+		filename := filepath.Join(h.root, filepath.FromSlash(file.Path))
+		if file.Existed {
+			var content []byte
+			content, err = ioutil.ReadFile(filepath.Join(entry.dir, "files", filepath.FromSlash(file.Path)))
+			if err == nil {
+				err = atomicWrite(filename, content)
+			}
+		} else {
+			err = os.Remove(filename)
+			if errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+		}
+
+		if err == nil {
+			Logger.Logf("<success>%s</success> restored", file.Path)
+		}
+	}
+
+	if err == nil {
+		err = os.RemoveAll(entry.dir)
+	}
+	return err
+}
+
+// Undo reverts the files changed by the most recent command
+// and returns the command that was undone
+func (h *History) Undo() (command string, err error) {
+	entries, err := h.entries()
+	if err == nil {
+		if len(entries) == 0 {
+			err = ErrNoHistory
+		} else {
+			last := entries[len(entries)-1]
+			command = last.Command
+			err = h.revert(last)
+		}
+	}
+	return
+}
+
+// atomicWrite writes the content to a temporary file in the same
+// directory as filename and then renames it over filename so
+// readers either see the old content or the new content
+func atomicWrite(filename string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("Failed to write %q: %w", filename, err)
+	}
+
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+
+	// replaced files keep their mode so that scripts stay executable
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		err = fmt.Errorf("Failed to write %q: %w", filename, err)
+	}
+	return err
+}
+
+// fileContent is a file that is about to be written
+type fileContent struct {
+	name    string
+	content []byte
+}
+
+// writeFiles records the previous content of each file in the history
+// and then atomically replaces each of them.  If any file can't be
+// written then the files that were already written are restored
+func writeFiles(files ...fileContent) (err error) {
+	if checking {
+		for _, file := range files {
+			content, err := ioutil.ReadFile(file.name)
+			if err != nil || !bytes.Equal(content, file.content) {
+				fileChanged(file.name)
+			}
+		}
+		return nil
+	}
+
+	type original struct {
+		content []byte
+		existed bool
+	}
+
+	// devices and pipes (ie /dev/stdout) can't be replaced or recorded,
+	// so they are written directly.  Every file is checked before any
+	// are recorded so that a failure doesn't leave partial history
+	for _, file := range files {
+		if info, err := os.Stat(file.name); err == nil && !info.Mode().IsRegular() {
+			if len(files) > 1 {
+				return fmt.Errorf("Failed to write %q: not a regular file", file.name)
+			}
+			return ioutil.WriteFile(file.name, file.content, 0644)
+		}
+	}
+
+	originals := []original{}
+	for _, file := range files {
+		if history != nil {
+			if err = history.record(file.name); err != nil {
+				return fmt.Errorf("Failed to record history for %q: %w", file.name, err)
+			}
+		}
+
+		orig := original{existed: true}
+		orig.content, err = ioutil.ReadFile(file.name)
+		if errors.Is(err, fs.ErrNotExist) {
+			orig.existed, err = false, nil
+		} else if err != nil {
+			return fmt.Errorf("Failed to read %q: %w", file.name, err)
+		}
+		originals = append(originals, orig)
+	}
+
+	for i, file := range files {
+		if err = atomicWrite(file.name, file.content); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				if originals[j].existed {
+					atomicWrite(files[j].name, originals[j].content)
+				} else {
+					os.Remove(files[j].name)
+				}
+			}
+			break
+		}
+	}
+	return err
+}
+
+// WriteFile atomically replaces the content of filename.  The
+// previous content is recorded in the history so that it can
+// be restored with "waffle undo"
+func WriteFile(filename string, content []byte) error {
+	return writeFiles(fileContent{filename, content})
+}
+
+// RemoveFile removes filename after recording its content
+// in the history so that it can be restored with "waffle undo"
+func RemoveFile(filename string) error {
+	if checking {
+		if _, err := os.Stat(filename); err == nil {
+			fileChanged(filename)
+		}
+		return nil
+	}
+
+	var err error
+	if history != nil {
+		err = history.record(filename)
+	}
+
+	if err == nil {
+		err = os.Remove(filename)
+	}
+	return err
+}
+
+func fileChanged(filename string) {
+	for _, name := range changedFiles {
+		if name == filename {
+			return
+		}
+	}
+	changedFiles = append(changedFiles, filename)
+}
+
+// CheckWrites runs fn without writing or removing any files and
+// returns the files that fn would have changed
+func CheckWrites(fn func() error) ([]string, error) {
+	checking, changedFiles = true, nil
+	defer func() { checking = false }()
+	err := fn()
+	return changedFiles, err
+}
@@ -0,0 +1,121 @@
+package waffle
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readTestFile(t *testing.T, filename string) string {
+	t.Helper()
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", filename, err)
+	}
+	return string(content)
+}
+
+func TestHistoryUndo(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "existing.txt")
+	created := filepath.Join(root, "created.txt")
+	writeTestFile(t, existing, "before\n")
+
+	h, err := OpenHistory(root, "test")
+	if err == nil {
+		err = WriteFile(existing, []byte("after\n"))
+	}
+
+	if err == nil {
+		err = WriteFile(created, []byte("new\n"))
+	}
+
+	if err == nil {
+		err = h.Close()
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	command, err := h.Undo()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if command != "test" {
+		t.Errorf("Wanted command %q got %q", "test", command)
+	}
+
+	if got := readTestFile(t, existing); got != "before\n" {
+		t.Errorf("Wanted %q got %q", "before\n", got)
+	}
+
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed got %v", created, err)
+	}
+
+	if _, err := h.Undo(); err != ErrNoHistory {
+		t.Errorf("Wanted %v got %v", ErrNoHistory, err)
+	}
+}
+
+func TestHistoryOutsideRoot(t *testing.T) {
+	dir := t.TempDir()
+	// deep enough that the path relative to the root would
+	// climb out of the history entry
+	root := filepath.Join(dir, "a", "project")
+	outside := filepath.Join(dir, "x", "export.json")
+	writeTestFile(t, filepath.Join(root, "project.json"), "{}\n")
+
+	writeTestFile(t, outside, "old\n")
+	h, err := OpenHistory(root, "export")
+	if err == nil {
+		err = WriteFile(outside, []byte("{}\n"))
+	}
+
+	if err == nil {
+		err = h.Close()
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := readTestFile(t, outside); got != "{}\n" {
+		t.Errorf("Wanted %q got %q", "{}\n", got)
+	}
+
+	// the history must still be readable by the next command
+	if _, err = OpenHistory(root, "next"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err = h.Undo(); err != ErrNoHistory {
+		t.Errorf("Wanted %v got %v", ErrNoHistory, err)
+	}
+	history = nil
+}
+
+func TestHistoryWithoutManifest(t *testing.T) {
+	root := t.TempDir()
+	orphan := filepath.Join(root, filepath.FromSlash(DefHistoryDir), "00000000000000000001")
+	if err := os.MkdirAll(orphan, 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	h, err := OpenHistory(root, "test")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	history = nil
+
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed got %v", orphan, err)
+	}
+
+	if _, err = h.Undo(); err != ErrNoHistory {
+		t.Errorf("Wanted %v got %v", ErrNoHistory, err)
+	}
+}
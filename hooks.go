@@ -0,0 +1,124 @@
+package waffle
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+const (
+	ErrHookChained = WaffleError("a chained hook already exists")
+
+	// hookMarker identifies hooks that were written by waffle
+	hookMarker = "# installed by waffle hooks install"
+
+	// chainedSuffix is appended to the name of a hook that
+	// existed before waffle's hook was installed
+	chainedSuffix = ".chained"
+)
+
+// Hooks are the git hooks that "waffle hooks install" writes
+var Hooks = []string{"pre-commit", "pre-push"}
+
+// HooksDir returns the directory git runs hooks from.  This is
+// core.hooksPath if it is set, otherwise the hooks directory in
+// the git directory
+func (gr *GitRepo) HooksDir() (string, error) {
+	cfg, err := gr.repo.Config()
+	if err != nil {
+		return "", err
+	}
+
+	if dir := cfg.Raw.Section("core").Option("hooksPath"); dir != "" {
+		if !filepath.IsAbs(dir) {
+			wt, err := gr.repo.Worktree()
+			if err != nil {
+				return "", err
+			}
+			dir = filepath.Join(wt.Filesystem.Root(), dir)
+		}
+		return dir, nil
+	}
+
+	storage, ok := gr.repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return "", fmt.Errorf("%w: repo is not stored on disk", ErrNoGitRepo)
+	}
+	return filepath.Join(storage.Filesystem().Root(), "hooks"), nil
+}
+
+// hookScript returns a hook that runs the chained hook, if there
+// is one, and then checks that the generated code is up to date
+// and the OpenAPI document is valid
+func (gr *GitRepo) hookScript(name string) string {
+	dir := strings.TrimSuffix(gr.prefix, "/")
+	if dir == "" {
+		dir = "."
+	}
+
+	return fmt.Sprintf(`#!/bin/sh
+%s
+# The %[2]s hook that existed before waffle's is run first
+chained="$(dirname "$0")/%[2]s%[3]s"
+if [ -x "$chained" ]; then
+	"$chained" "$@" || exit $?
+fi
+
+if ! command -v waffle >/dev/null 2>&1; then
+	echo "waffle is not installed, skipping generated code checks" >&2
+	exit 0
+fi
+
+waffle -C %[4]q generate -check && waffle -C %[4]q api validate
+`, hookMarker, name, chainedSuffix, dir)
+}
+
+// InstallHook writes the named waffle hook.  A hook that wasn't
+// written by waffle is kept and run before the waffle checks
+func (gr *GitRepo) InstallHook(name string) error {
+	dir, err := gr.HooksDir()
+	if err == nil {
+		err = os.MkdirAll(dir, 0755)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	filename := filepath.Join(dir, name)
+	chained := filename + chainedSuffix
+	content, err := ioutil.ReadFile(filename)
+	if err == nil && !strings.Contains(string(content), hookMarker) {
+		if _, err = os.Stat(chained); err == nil {
+			return fmt.Errorf("%w: %s", ErrHookChained, chained)
+		}
+
+		err = WriteFile(chained, content)
+		if err == nil {
+			err = os.Chmod(chained, 0755)
+		}
+
+		if err == nil {
+			Logger.Logf("Existing <hl>%s</hl> hook moved to <hl>%s</hl>", name, filepath.Base(chained))
+		}
+	}
+
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+
+	if err == nil {
+		err = WriteFile(filename, []byte(gr.hookScript(name)))
+	}
+
+	if err == nil {
+		err = os.Chmod(filename, 0755)
+	}
+	return err
+}
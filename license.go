@@ -0,0 +1,202 @@
+package waffle
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+//go:embed internal/licenses
+var licenses embed.FS
+
+const (
+	// DefLicenseFile is the default filename for the project license
+	DefLicenseFile = "LICENSE"
+
+	ErrUnknownLicense = WaffleError("unknown license")
+)
+
+// License is one of the licenses that waffle can generate
+type License struct {
+	// ID is the SPDX license identifier
+	ID string
+	// Name is the full name of the license
+	Name string
+}
+
+// URL returns a link to the license on spdx.org
+func (l License) URL() string {
+	return fmt.Sprintf("https://spdx.org/licenses/%s.html", l.ID)
+}
+
+// Licenses is the list of built-in licenses
+var Licenses = []License{
+	{"Apache-2.0", "Apache License 2.0"},
+	{"BSD-2-Clause", "BSD 2-Clause \"Simplified\" License"},
+	{"BSD-3-Clause", "BSD 3-Clause \"New\" or \"Revised\" License"},
+	{"ISC", "ISC License"},
+	{"MIT", "MIT License"},
+	{"MPL-2.0", "Mozilla Public License 2.0"},
+}
+
+// LookupLicense finds the built-in license with the given
+// SPDX identifier.  The lookup is case insensitive
+func LookupLicense(id string) (License, error) {
+	for _, license := range Licenses {
+		if strings.EqualFold(license.ID, id) {
+			return license, nil
+		}
+	}
+	return License{}, fmt.Errorf("%w %q", ErrUnknownLicense, id)
+}
+
+// CheckLicense makes sure that, if set, the license is
+// one of the built-in licenses
+func CheckLicense(id string) error {
+	if id == "" {
+		return nil
+	}
+
+	_, err := LookupLicense(id)
+	if err != nil {
+		ids := []string{}
+		for _, license := range Licenses {
+			ids = append(ids, license.ID)
+		}
+		err = fmt.Errorf("%w, expected one of %s", err, strings.Join(ids, ", "))
+	}
+	return err
+}
+
+// Maintainers is a list of people responsible for a project.  Maintainers
+// satisfies the flag.Value interface so that a flag can be repeated
+// to add several people
+type Maintainers []Maintainer
+
+// String returns the comma separated list of maintainers
+func (m *Maintainers) String() string {
+	strs := []string{}
+	for _, maintainer := range *m {
+		strs = append(strs, maintainer.String())
+	}
+	return strings.Join(strs, ", ")
+}
+
+// Set parses a string of the form "Name <email>" and
+// appends it to the list
+func (m *Maintainers) Set(str string) error {
+	maintainer := Maintainer{Name: strings.TrimSpace(str)}
+	if i := strings.Index(str, "<"); i >= 0 && strings.HasSuffix(str, ">") {
+		maintainer.Name = strings.TrimSpace(str[:i])
+		maintainer.Email = str[i+1 : len(str)-1]
+	}
+
+	err := CheckEmail(maintainer.Email)
+	if err == nil {
+		*m = append(*m, maintainer)
+	}
+	return err
+}
+
+// CopyrightHolder is the organization that owns the
+// project or, if that isn't set, the maintainer
+func (c *Config) CopyrightHolder() string {
+	if c.Org != "" {
+		return c.Org
+	}
+	return c.Maintainer.Name
+}
+
+// LicenseName returns the full name of the project license
+func (c *Config) LicenseName() string {
+	license, err := LookupLicense(c.License)
+	if err == nil {
+		return license.Name
+	}
+	return c.License
+}
+
+// CopyrightYear is the year in the license.  If the config doesn't
+// have a year then the year of the first git commit is used, if there
+// are no commits then zero is returned and the year is left out
+func (c *Config) CopyrightYear() int {
+	if c.Year != 0 {
+		return c.Year
+	}
+
+	year := 0
+	repo, err := OpenGit(c.Dir())
+	if err == nil {
+		year, _ = repo.FirstCommitYear()
+	}
+	return year
+}
+
+type licenseData struct {
+	ID     string
+	Year   int
+	Holder string
+}
+
+func (c *Config) executeLicense(filename string) (content []byte, err error) {
+	license, err := LookupLicense(c.License)
+	if err == nil {
+		var tmpl *template.Template
+		tmpl, err = template.ParseFS(licenses, "internal/licenses/"+filename)
+		if err == nil {
+			buf := &bytes.Buffer{}
+			err = tmpl.Execute(buf, licenseData{
+				ID:     license.ID,
+				Year:   c.CopyrightYear(),
+				Holder: c.CopyrightHolder(),
+			})
+			content = buf.Bytes()
+		}
+	}
+	return
+}
+
+// LicenseHeader returns the comment that generated Go source files
+// should start with.  An empty string is returned if the project
+// doesn't have a license
+func (c *Config) LicenseHeader() string {
+	if c.License == "" {
+		return ""
+	}
+
+	license, err := LookupLicense(c.License)
+	if err != nil {
+		return ""
+	}
+
+	content, err := c.executeLicense(license.ID + ".header.tmpl")
+	if err != nil {
+		content, err = c.executeLicense("header.tmpl")
+	}
+
+	if err != nil {
+		Logger.Logf("<fail>Failed</fail> to generate license header: %v", err)
+		return ""
+	}
+	return string(content) + "\n"
+}
+
+// WriteLicense writes the full text of the project license to
+// filename.  Nothing is written if the project doesn't have a license
+func (c *Config) WriteLicense(filename string) error {
+	if c.License == "" {
+		return nil
+	}
+
+	license, err := LookupLicense(c.License)
+	if err == nil {
+		var content []byte
+		content, err = c.executeLicense(license.ID + ".tmpl")
+		if err == nil {
+			err = WriteFile(filename, content)
+		}
+	}
+	return err
+}
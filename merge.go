@@ -0,0 +1,241 @@
+package waffle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// DefGeneratedDir is where the last generated content of each
+	// file is kept relative to the project root.  The directory should
+	// be committed along with the generated files so that everyone
+	// merges against the same content
+	DefGeneratedDir = ".waffle/generated"
+
+	ErrMergeConflict = WaffleError("merge conflict")
+)
+
+// splitLines splits the content into lines, each line
+// keeps its newline
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matchLines finds the longest common subsequence of base and other.  The
+// returned slice has an entry for each line of base that is the index
+// of the matching line in other or -1 if the line isn't matched
+func matchLines(base, other []string) []int {
+	// lengths[i][j] is the LCS length of base[i:] and other[j:]
+	lengths := make([][]int, len(base)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(other)+1)
+	}
+
+	for i := len(base) - 1; i >= 0; i-- {
+		for j := len(other) - 1; j >= 0; j-- {
+			if base[i] == other[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	matches := make([]int, len(base))
+	i, j := 0, 0
+	for i < len(base) {
+		switch {
+		case j < len(other) && base[i] == other[j]:
+			matches[i] = j
+			i++
+			j++
+		case j < len(other) && lengths[i][j+1] > lengths[i+1][j]:
+			j++
+		default:
+			matches[i] = -1
+			i++
+		}
+	}
+	return matches
+}
+
+func equalLines(l1, l2 []string) bool {
+	if len(l1) != len(l2) {
+		return false
+	}
+
+	for i := range l1 {
+		if l1[i] != l2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeLines writes the lines and returns true if a newline had
+// to be added after the last line
+func writeLines(buf *bytes.Buffer, lines []string) bool {
+	for _, line := range lines {
+		buf.WriteString(line)
+	}
+
+	// conflict markers must start on their own line
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		buf.WriteString("\n")
+		return true
+	}
+	return false
+}
+
+// merge3 merges the changes made to base by ours and by theirs.  Changes
+// that don't overlap are combined, changes that do overlap are written
+// between conflict markers with our version first.  The number of
+// conflicts is returned along with the merged content
+func merge3(name string, base, ours, theirs []byte) ([]byte, int) {
+	baseLines, ourLines, theirLines := splitLines(base), splitLines(ours), splitLines(theirs)
+	ourMatches, theirMatches := matchLines(baseLines, ourLines), matchLines(baseLines, theirLines)
+
+	buf := &bytes.Buffer{}
+	conflicts := 0
+
+	// addedNewline is set when the last chunk written ended
+	// without a newline and one was added after it
+	addedNewline := false
+	writeChunk := func(lines []string) {
+		if len(lines) > 0 {
+			addedNewline = writeLines(buf, lines)
+		}
+	}
+
+	i, o, t := 0, 0, 0
+	for i < len(baseLines) || o < len(ourLines) || t < len(theirLines) {
+		// lines unchanged on both sides are copied as is
+		if i < len(baseLines) && ourMatches[i] == o && theirMatches[i] == t {
+			buf.WriteString(baseLines[i])
+			addedNewline = false
+			i, o, t = i+1, o+1, t+1
+			continue
+		}
+
+		// find the end of the changed chunk, which is the next
+		// base line that both sides kept
+		j := i
+		for j < len(baseLines) && (ourMatches[j] < 0 || theirMatches[j] < 0) {
+			j++
+		}
+
+		oEnd, tEnd := len(ourLines), len(theirLines)
+		if j < len(baseLines) {
+			oEnd, tEnd = ourMatches[j], theirMatches[j]
+		}
+
+		baseChunk, ourChunk, theirChunk := baseLines[i:j], ourLines[o:oEnd], theirLines[t:tEnd]
+		switch {
+		case equalLines(ourChunk, baseChunk):
+			writeChunk(theirChunk)
+		case equalLines(theirChunk, baseChunk), equalLines(ourChunk, theirChunk):
+			writeChunk(ourChunk)
+		default:
+			conflicts++
+			fmt.Fprintf(buf, "<<<<<<< %s (local)\n", name)
+			writeLines(buf, ourChunk)
+			buf.WriteString("=======\n")
+			writeLines(buf, theirChunk)
+			fmt.Fprintf(buf, ">>>>>>> %s (generated)\n", name)
+			addedNewline = false
+		}
+		i, o, t = j, oEnd, tEnd
+	}
+
+	// a missing final newline is kept if the side
+	// the last chunk was taken from was missing it
+	merged := buf.Bytes()
+	if addedNewline {
+		merged = merged[:len(merged)-1]
+	}
+	return merged, conflicts
+}
+
+// generatedFile returns the name of the file holding the last
+// generated content of filename, which is relative to root
+func generatedFile(root, filename string) string {
+	return filepath.Join(root, filepath.FromSlash(DefGeneratedDir), filepath.FromSlash(filename))
+}
+
+// mergeGenerated merges newly generated content with any local changes
+// that were made to the file since it was last generated.  If the last
+// generated content wasn't recorded then the lines common to both
+// versions are used in its place.  The number of conflicts is returned
+// along with the content that should be written
+func mergeGenerated(root, filename string, generated []byte) ([]byte, int, error) {
+	local, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(filename)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return generated, 0, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	base, err := ioutil.ReadFile(generatedFile(root, filename))
+	if errors.Is(err, fs.ErrNotExist) {
+		if bytes.Equal(local, generated) {
+			return generated, 0, nil
+		}
+
+		// without the previous content only the lines both
+		// versions have in common can be merged
+		base, err = commonLines(local, generated), nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	if bytes.Equal(local, base) {
+		return generated, 0, nil
+	}
+
+	merged, conflicts := merge3(filename, base, local, generated)
+	return merged, conflicts, nil
+}
+
+// commonLines returns the longest common subsequence of
+// lines in both c1 and c2
+func commonLines(c1, c2 []byte) []byte {
+	lines := splitLines(c1)
+	buf := &bytes.Buffer{}
+	for i, j := range matchLines(lines, splitLines(c2)) {
+		if j >= 0 {
+			buf.WriteString(lines[i])
+		}
+	}
+	return buf.Bytes()
+}
+
+// RemoveGenerated removes a generated file, and the record of
+// its last generated content, from the project in root
+func RemoveGenerated(root, filename string) error {
+	err := RemoveFile(filepath.Join(root, filepath.FromSlash(filename)))
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+
+	if err == nil {
+		err = RemoveFile(generatedFile(root, filename))
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	}
+	return err
+}
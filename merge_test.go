@@ -0,0 +1,308 @@
+package waffle
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatchLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  []string
+		other []string
+		want  []int
+	}{
+		{"equal", []string{"a", "b", "c"}, []string{"a", "b", "c"}, []int{0, 1, 2}},
+		{"removed", []string{"a", "b", "c"}, []string{"a", "c"}, []int{0, -1, 1}},
+		{"inserted", []string{"a", "b"}, []string{"x", "a", "y", "b"}, []int{1, 3}},
+		{"replaced", []string{"a", "b", "c"}, []string{"a", "x", "c"}, []int{0, -1, 2}},
+		{"moved", []string{"a", "b", "c"}, []string{"c", "a", "b"}, []int{1, 2, -1}},
+		{"empty other", []string{"a", "b"}, nil, []int{-1, -1}},
+		{"empty base", nil, []string{"a", "b"}, []int{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := matchLines(test.base, test.other)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("Wanted %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestCommonLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		c1, c2 string
+		want   string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", "a\nb\n"},
+		{"disjoint", "a\nb\n", "c\nd\n", ""},
+		{"insertions", "a\nx\nb\n", "a\nb\ny\n", "a\nb\n"},
+		{"empty", "", "a\n", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := string(commonLines([]byte(test.c1), []byte(test.c2)))
+			if got != test.want {
+				t.Errorf("Wanted %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name          string
+		base          string
+		ours          string
+		theirs        string
+		want          string
+		wantConflicts int
+	}{
+		{
+			name:   "unchanged",
+			base:   "a\nb\n",
+			ours:   "a\nb\n",
+			theirs: "a\nb\n",
+			want:   "a\nb\n",
+		},
+		{
+			name:   "only ours changed",
+			base:   "a\nb\nc\n",
+			ours:   "a\nB\nc\n",
+			theirs: "a\nb\nc\n",
+			want:   "a\nB\nc\n",
+		},
+		{
+			name:   "only theirs changed",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\n",
+			theirs: "a\nb\nC\n",
+			want:   "a\nb\nC\n",
+		},
+		{
+			name:   "non-overlapping edits",
+			base:   "a\nb\nc\nd\ne\n",
+			ours:   "a\nB\nc\nd\ne\n",
+			theirs: "a\nb\nc\nD\ne\n",
+			want:   "a\nB\nc\nD\ne\n",
+		},
+		{
+			name:   "non-overlapping inserts and removals",
+			base:   "a\nb\nc\nd\n",
+			ours:   "x\na\nb\nc\nd\n",
+			theirs: "a\nb\nd\ny\n",
+			want:   "x\na\nb\nd\ny\n",
+		},
+		{
+			name:   "identical edits",
+			base:   "a\nb\nc\n",
+			ours:   "a\nX\nc\nd\n",
+			theirs: "a\nX\nc\nd\n",
+			want:   "a\nX\nc\nd\n",
+		},
+		{
+			name:          "conflicting edits",
+			base:          "a\nb\nc\n",
+			ours:          "a\nours\nc\n",
+			theirs:        "a\ntheirs\nc\n",
+			want:          "a\n<<<<<<< f.go (local)\nours\n=======\ntheirs\n>>>>>>> f.go (generated)\nc\n",
+			wantConflicts: 1,
+		},
+		{
+			name:          "conflicting inserts at the same point",
+			base:          "a\nb\n",
+			ours:          "a\nours\nb\n",
+			theirs:        "a\ntheirs\nb\n",
+			want:          "a\n<<<<<<< f.go (local)\nours\n=======\ntheirs\n>>>>>>> f.go (generated)\nb\n",
+			wantConflicts: 1,
+		},
+		{
+			name:          "conflicting inserts at the end",
+			base:          "a\n",
+			ours:          "a\nours\n",
+			theirs:        "a\ntheirs\n",
+			want:          "a\n<<<<<<< f.go (local)\nours\n=======\ntheirs\n>>>>>>> f.go (generated)\n",
+			wantConflicts: 1,
+		},
+		{
+			name:          "edit and removal",
+			base:          "a\nb\nc\n",
+			ours:          "a\nB\nc\n",
+			theirs:        "a\nc\n",
+			want:          "a\n<<<<<<< f.go (local)\nB\n=======\n>>>>>>> f.go (generated)\nc\n",
+			wantConflicts: 1,
+		},
+		{
+			name:          "two conflicts",
+			base:          "a\nb\nc\nd\ne\n",
+			ours:          "a\nB1\nc\nD1\ne\n",
+			theirs:        "a\nB2\nc\nD2\ne\n",
+			want:          "a\n<<<<<<< f.go (local)\nB1\n=======\nB2\n>>>>>>> f.go (generated)\nc\n<<<<<<< f.go (local)\nD1\n=======\nD2\n>>>>>>> f.go (generated)\ne\n",
+			wantConflicts: 2,
+		},
+		{
+			name:   "empty base",
+			base:   "",
+			ours:   "a\n",
+			theirs: "a\n",
+			want:   "a\n",
+		},
+
+		// files that end without a newline
+		{
+			name:   "no newline unchanged",
+			base:   "a\nb",
+			ours:   "a\nb",
+			theirs: "a\nb",
+			want:   "a\nb",
+		},
+		{
+			name:   "no newline edited by theirs",
+			base:   "a\nb",
+			ours:   "x\na\nb",
+			theirs: "a\nB",
+			want:   "x\na\nB",
+		},
+		{
+			name:   "no newline only on the chosen side",
+			base:   "a\n",
+			ours:   "a\n",
+			theirs: "a\nb",
+			want:   "a\nb",
+		},
+		{
+			name:   "newline added by ours",
+			base:   "a\nb",
+			ours:   "a\nb\nc\n",
+			theirs: "a\nb",
+			want:   "a\nb\nc\n",
+		},
+		{
+			name:          "no newline conflict",
+			base:          "a\nb",
+			ours:          "a\nours",
+			theirs:        "a\ntheirs",
+			want:          "a\n<<<<<<< f.go (local)\nours\n=======\ntheirs\n>>>>>>> f.go (generated)\n",
+			wantConflicts: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, conflicts := merge3("f.go", []byte(test.base), []byte(test.ours), []byte(test.theirs))
+			if string(got) != test.want {
+				t.Errorf("Wanted %q got %q", test.want, string(got))
+			}
+
+			if conflicts != test.wantConflicts {
+				t.Errorf("Wanted %d conflicts got %d", test.wantConflicts, conflicts)
+			}
+		})
+	}
+}
+
+func TestMergeGenerated(t *testing.T) {
+	tests := []struct {
+		name          string
+		local         *string
+		base          *string
+		generated     string
+		want          string
+		wantConflicts int
+	}{
+		{
+			name:      "new file",
+			generated: "a\n",
+			want:      "a\n",
+		},
+		{
+			name:      "local unchanged",
+			local:     strPtr("a\nb\n"),
+			base:      strPtr("a\nb\n"),
+			generated: "a\nB\n",
+			want:      "a\nB\n",
+		},
+		{
+			name:      "local changes kept",
+			local:     strPtr("a\nlocal\nb\n"),
+			base:      strPtr("a\nb\n"),
+			generated: "a\nb\ngenerated\n",
+			want:      "a\nlocal\nb\ngenerated\n",
+		},
+		{
+			name:          "local changes conflict",
+			local:         strPtr("a\nlocal\n"),
+			base:          strPtr("a\nb\n"),
+			generated:     "a\ngenerated\n",
+			want:          "a\n<<<<<<< f.go (local)\nlocal\n=======\ngenerated\n>>>>>>> f.go (generated)\n",
+			wantConflicts: 1,
+		},
+		{
+			name:      "no base and local matches",
+			local:     strPtr("a\nb\n"),
+			generated: "a\nb\n",
+			want:      "a\nb\n",
+		},
+		{
+			// without a base the common lines are merged
+			name:      "no base",
+			local:     strPtr("a\nlocal\nb\n"),
+			generated: "a\nb\ngenerated\n",
+			want:      "a\nlocal\nb\ngenerated\n",
+		},
+		{
+			name:          "no base and both changed",
+			local:         strPtr("a\nlocal\nb\n"),
+			generated:     "a\ngenerated\nb\n",
+			want:          "a\n<<<<<<< f.go (local)\nlocal\n=======\ngenerated\n>>>>>>> f.go (generated)\nb\n",
+			wantConflicts: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			if test.local != nil {
+				writeTestFile(t, filepath.Join(root, "f.go"), *test.local)
+			}
+
+			if test.base != nil {
+				writeTestFile(t, generatedFile(root, "f.go"), *test.base)
+			}
+
+			got, conflicts, err := mergeGenerated(root, "f.go", []byte(test.generated))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if string(got) != test.want {
+				t.Errorf("Wanted %q got %q", test.want, string(got))
+			}
+
+			if conflicts != test.wantConflicts {
+				t.Errorf("Wanted %d conflicts got %d", test.wantConflicts, conflicts)
+			}
+		})
+	}
+}
+
+func strPtr(str string) *string { return &str }
+
+func writeTestFile(t *testing.T, filename, content string) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(filename, []byte(content), 0644)
+	}
+
+	if err != nil {
+		t.Fatalf("Failed to write %s: %v", filename, err)
+	}
+}
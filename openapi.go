@@ -0,0 +1,327 @@
+package waffle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// APIError is a single problem found in an OpenAPI document
+type APIError struct {
+	// File is the name of the OpenAPI document
+	File string
+	// Line is the line number of the invalid value or zero if
+	// the line could not be determined
+	Line int
+	// Pointer is the JSON pointer (RFC 6901) to the invalid value
+	Pointer string
+	// Err is the reason the value is invalid
+	Err error
+}
+
+func (ae APIError) Error() string {
+	location := ae.File
+	if ae.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, ae.Line)
+	}
+
+	pointer := ae.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	return fmt.Sprintf("%s: %s: %v", location, pointer, ae.Err)
+}
+
+func (ae APIError) Unwrap() error { return ae.Err }
+
+// APIValidationError is the list of all the problems found
+// in an OpenAPI document
+type APIValidationError []APIError
+
+func (ave APIValidationError) Error() string {
+	builder := &strings.Builder{}
+	builder.WriteString("invalid OpenAPI document:")
+	for _, ae := range ave {
+		fmt.Fprintf(builder, "\n    %v", ae)
+	}
+	return builder.String()
+}
+
+// escapePointer escapes a JSON pointer reference token
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// apiValidator walks an OpenAPI document validating each
+// section individually so that problems can be reported
+// with their location
+type apiValidator struct {
+	ctx     context.Context
+	file    string
+	content []byte
+	errors  APIValidationError
+}
+
+func (av *apiValidator) add(pointer string, err error) bool {
+	if err != nil {
+		av.errors = append(av.errors, APIError{
+			File:    av.file,
+			Line:    pointerLine(av.content, pointer),
+			Pointer: pointer,
+			Err:     err,
+		})
+	}
+	return err != nil
+}
+
+func (av *apiValidator) validateComponents(components openapi3.Components) {
+	failed := false
+	add := func(section, name string, err error) {
+		failed = av.add(fmt.Sprintf("/components/%s/%s", section, escapePointer(name)), err) || failed
+	}
+
+	for name, v := range components.Schemas {
+		add("schemas", name, v.Validate(av.ctx))
+	}
+
+	for name, v := range components.Parameters {
+		add("parameters", name, v.Validate(av.ctx))
+	}
+
+	for name, v := range components.Headers {
+		add("headers", name, v.Validate(av.ctx))
+	}
+
+	for name, v := range components.RequestBodies {
+		add("requestBodies", name, v.Validate(av.ctx))
+	}
+
+	for name, v := range components.Responses {
+		add("responses", name, v.Validate(av.ctx))
+	}
+
+	for name, v := range components.SecuritySchemes {
+		add("securitySchemes", name, v.Validate(av.ctx))
+	}
+
+	for name, v := range components.Examples {
+		add("examples", name, v.Validate(av.ctx))
+	}
+
+	for name, v := range components.Links {
+		add("links", name, v.Validate(av.ctx))
+	}
+
+	for name, v := range components.Callbacks {
+		add("callbacks", name, v.Validate(av.ctx))
+	}
+
+	// catch anything that isn't specific to a single component
+	// such as invalid component names
+	if !failed {
+		av.add("/components", components.Validate(av.ctx))
+	}
+}
+
+func (av *apiValidator) validatePaths(paths openapi3.Paths) {
+	if paths == nil {
+		av.add("/paths", errors.New("must be an object"))
+		return
+	}
+
+	keys := []string{}
+	for path := range paths {
+		keys = append(keys, path)
+	}
+	sort.Strings(keys)
+
+	failed := false
+	for _, path := range keys {
+		pointer := "/paths/" + escapePointer(path)
+		if !strings.HasPrefix(path, "/") {
+			failed = av.add(pointer, fmt.Errorf("path %q does not start with a forward slash (/)", path)) || failed
+			continue
+		}
+
+		for method, operation := range paths[path].Operations() {
+			err := operation.Validate(av.ctx)
+			failed = av.add(pointer+"/"+strings.ToLower(method), err) || failed
+		}
+	}
+
+	// catch problems between paths such as conflicting templates
+	if !failed {
+		av.add("/paths", paths.Validate(av.ctx))
+	}
+}
+
+func (av *apiValidator) validate(doc *openapi3.T) APIValidationError {
+	if doc.OpenAPI == "" {
+		av.add("/openapi", errors.New("must be a non-empty string"))
+	}
+
+	if doc.Info == nil {
+		av.add("/info", errors.New("must be an object"))
+	} else {
+		av.add("/info", doc.Info.Validate(av.ctx))
+	}
+
+	av.validateComponents(doc.Components)
+	av.validatePaths(doc.Paths)
+
+	for i, server := range doc.Servers {
+		av.add(fmt.Sprintf("/servers/%d", i), server.Validate(av.ctx))
+	}
+
+	if doc.Security != nil {
+		av.add("/security", doc.Security.Validate(av.ctx))
+	}
+	return av.errors
+}
+
+// ValidateAPI validates the OpenAPI document.  The content is the
+// JSON encoding of the document and is used to determine line numbers
+// for any problems found.  If the document is invalid an
+// APIValidationError is returned
+func ValidateAPI(doc *openapi3.T, content []byte, filename string) error {
+	av := &apiValidator{ctx: context.Background(), file: filename, content: content}
+	if errs := av.validate(doc); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// loadAPI reads an OpenAPI 3.0 or 3.1 document.  Documents are
+// converted to 3.0 for kin-openapi and any conversion warnings are
+// logged.  The original file content is returned so that problems
+// can be reported with line numbers
+func loadAPI(filename string) (doc *openapi3.T, content []byte, err error) {
+	content, err = ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to load %q: %w", filename, err)
+	}
+
+	var v interface{}
+	if err = json.Unmarshal(content, &v); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, content, APIValidationError{{File: filename, Line: offsetLine(content, syntaxErr.Offset), Err: err}}
+		}
+		return nil, content, APIValidationError{{File: filename, Err: err}}
+	}
+
+	data := content
+	version := apiVersion(content)
+	switch {
+	case strings.HasPrefix(version, "3.0."):
+	case strings.HasPrefix(version, "3.1."):
+		var warnings []string
+		data, warnings, err = DowngradeAPI(content, false)
+		for _, warning := range warnings {
+			Logger.Logf("<warn>Warning</warn>: %s: %s", filename, warning)
+		}
+	default:
+		err = fmt.Errorf("%w %q in %s, expected 3.0.x or 3.1.x", ErrUnsupportedAPIVersion, version, filename)
+	}
+
+	if err == nil {
+		doc, err = openapi3.NewLoader().LoadFromDataWithPath(data, &url.URL{Path: filepath.ToSlash(filename)})
+		if err != nil {
+			err = APIValidationError{{File: filename, Err: err}}
+		}
+	}
+	return doc, content, err
+}
+
+// ValidateAPIFile loads and validates an OpenAPI 3.0 or 3.1 document
+func ValidateAPIFile(filename string) error {
+	doc, content, err := loadAPI(filename)
+	if err == nil {
+		err = ValidateAPI(doc, content, filename)
+	}
+	return err
+}
+
+// offsetLine returns the line number of the byte offset
+func offsetLine(content []byte, offset int64) int {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
+
+// pointerLine finds the line number of the value referred to by a
+// JSON pointer.  For object members the line of the member name is
+// returned.  Zero is returned if the pointer can't be found
+func pointerLine(content []byte, pointer string) int {
+	if len(content) == 0 {
+		return 0
+	} else if pointer == "" {
+		return 1
+	}
+
+	type container struct {
+		path      string
+		array     bool
+		index     int
+		expectKey bool
+	}
+
+	var stack []*container
+	dec := json.NewDecoder(bytes.NewReader(content))
+	path := ""
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0
+		}
+
+		var top *container
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.index++
+				parent.expectKey = !parent.array
+			}
+			continue
+		}
+
+		if top != nil && top.expectKey {
+			path = top.path + "/" + escapePointer(tok.(string))
+			top.expectKey = false
+			if path == pointer {
+				return offsetLine(content, dec.InputOffset())
+			}
+			continue
+		}
+
+		if top != nil && top.array {
+			path = top.path + "/" + strconv.Itoa(top.index)
+			if path == pointer {
+				return offsetLine(content, dec.InputOffset())
+			}
+		}
+
+		if delim, ok := tok.(json.Delim); ok {
+			stack = append(stack, &container{path: path, array: delim == '[', expectKey: delim == '{'})
+		} else if top != nil {
+			top.index++
+			top.expectKey = !top.array
+		}
+	}
+}
@@ -1,14 +1,58 @@
 package waffle
 
 import (
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/manifoldco/promptui"
+	"github.com/mattn/go-isatty"
 )
 
+const (
+	ErrNoInput        = WaffleError("input is required but prompting is disabled")
+	ErrNotTerminal    = WaffleError("input is required but stdin is not a terminal")
+	ErrPromptCanceled = WaffleError("prompt was canceled")
+)
+
+// NoInput disables prompting.  Prompts return ErrNoInput instead
+var NoInput bool
+
 type Validator func(string) error
 
-func PromptStr(prompt string) string {
+// CanPrompt returns an error if the user can't be prompted, either
+// because prompting is disabled or stdin isn't a terminal
+func CanPrompt() error {
+	if NoInput {
+		return ErrNoInput
+	}
+
+	if fd := os.Stdin.Fd(); !isatty.IsTerminal(fd) && !isatty.IsCygwinTerminal(fd) {
+		return ErrNotTerminal
+	}
+	return nil
+}
+
+// canceled converts the errors promptui returns when the
+// user interrupts a prompt to ErrPromptCanceled
+func canceled(err error) error {
+	if errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) || errors.Is(err, promptui.ErrAbort) {
+		err = ErrPromptCanceled
+	}
+	return err
+}
+
+// run runs the prompt if the user can be prompted
+func run(p promptui.Prompt) (string, error) {
+	if err := CanPrompt(); err != nil {
+		return "", err
+	}
+
+	result, err := p.Run()
+	return result, canceled(err)
+}
+
+func PromptStr(prompt string) (string, error) {
 	return Prompt(prompt, func(str string) error {
 		for _, r := range str {
 			switch {
@@ -25,12 +69,111 @@ func PromptStr(prompt string) string {
 	})
 }
 
-func Prompt(prompt string, validator Validator) string {
-	p := promptui.Prompt{
+// Prompt asks the user for a value that passes the validator.  An error
+// is returned if the user can't be prompted or cancels the prompt
+func Prompt(prompt string, validator Validator) (string, error) {
+	return run(promptui.Prompt{
 		Label:    prompt,
 		Validate: promptui.ValidateFunc(validator),
+	})
+}
+
+// PromptPassword prompts for a secret without echoing it
+func PromptPassword(prompt string) (string, error) {
+	return run(promptui.Prompt{
+		Label: prompt,
+		Mask:  '*',
+	})
+}
+
+// PromptDefault asks the user for a value that passes the validator.  The
+// default is filled in so that it can be accepted or edited
+func PromptDefault(prompt, def string, validator Validator) (string, error) {
+	p := promptui.Prompt{
+		Label:     prompt,
+		Default:   def,
+		AllowEdit: true,
 	}
 
-	result, _ := p.Run()
-	return result
+	if validator != nil {
+		p.Validate = promptui.ValidateFunc(validator)
+	}
+	return run(p)
+}
+
+// PromptConfirm asks a yes or no question.  Pressing enter
+// without answering returns def
+func PromptConfirm(prompt string, def bool) (bool, error) {
+	p := promptui.Prompt{
+		Label:     prompt,
+		IsConfirm: true,
+	}
+
+	if def {
+		p.Default = "y"
+	}
+
+	if err := CanPrompt(); err != nil {
+		return false, err
+	}
+
+	_, err := p.Run()
+	if errors.Is(err, promptui.ErrAbort) {
+		// promptui aborts when the answer is no
+		return false, nil
+	}
+	return err == nil, canceled(err)
+}
+
+// PromptSelect asks the user to choose one of the items and returns
+// the index of the chosen item.  The cursor starts on item def
+func PromptSelect(prompt string, items []string, def int) (int, error) {
+	if err := CanPrompt(); err != nil {
+		return -1, err
+	}
+
+	p := promptui.Select{
+		Label:     prompt,
+		Items:     items,
+		CursorPos: def,
+		Size:      len(items),
+	}
+
+	i, _, err := p.Run()
+	return i, canceled(err)
+}
+
+// PromptMultiSelect asks the user to choose any number of the items.
+// Choosing an item toggles it and choosing "Done" ends the prompt.
+// Selected has the initial state of each item and is returned updated
+func PromptMultiSelect(prompt string, items []string, selected []bool) ([]bool, error) {
+	selected = append([]bool{}, selected...)
+	for len(selected) < len(items) {
+		selected = append(selected, false)
+	}
+
+	cursor := 0
+	for {
+		choices := []string{}
+		for i, item := range items {
+			box := "[ ]"
+			if selected[i] {
+				box = "[x]"
+			}
+			choices = append(choices, box+" "+item)
+		}
+		choices = append(choices, "Done")
+
+		i, err := PromptSelect(prompt, choices, cursor)
+		if err != nil || i == len(items) {
+			return selected, err
+		}
+		selected[i] = !selected[i]
+		cursor = i
+	}
+}
+
+// IsNoInput returns true if err is because the user couldn't be prompted
+func IsNoInput(err error) bool {
+	return errors.Is(err, ErrNoInput) || errors.Is(err, ErrNotTerminal)
 }
@@ -0,0 +1,267 @@
+package waffle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefSchemaFile is where the JSON Schema for the project config
+	// is written relative to the project root
+	DefSchemaFile = ".waffle/project.schema.json"
+
+	// SchemaDialect is the version of JSON Schema that is generated
+	SchemaDialect = "http://json-schema.org/draft-07/schema#"
+
+	// semverPattern matches a semantic version with an optional "v" prefix
+	semverPattern = `^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-((0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(\.(0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(\+([0-9a-zA-Z-]+(\.[0-9a-zA-Z-]+)*))?$`
+
+	// durationPattern matches the durations accepted by time.ParseDuration
+	durationPattern = `^[-+]?(0|(\d+(\.\d*)?|\.\d+)(ns|us|µs|ms|s|m|h))+$`
+
+	// namePattern matches the names accepted by CheckName, the name
+	// may be empty since whether it's required is checked separately
+	namePattern = `^([A-Za-z][A-Za-z0-9._-]*)?$`
+)
+
+// patternDescs describe the patterns for error messages
+var patternDescs = map[string]string{
+	semverPattern:   "a semantic version (ie 1.2.3 or 1.2.3-rc.1)",
+	durationPattern: "a duration (ie 15s or 1m30s)",
+	namePattern:     "a name that starts with a letter and contains only letters, digits, '.', '_' and '-'",
+	"^/":            "a path that starts with a slash",
+}
+
+// schemaTypes are the types that have their own string
+// encoding instead of the encoding of their kind
+var schemaTypes = map[reflect.Type]jsonObject{
+	reflect.TypeOf(Version{}): {
+		"type":        "string",
+		"pattern":     semverPattern,
+		"description": "Semantic version (ie 1.2.3 or 1.2.3-rc.1)",
+	},
+	reflect.TypeOf(Duration(0)): {
+		"type":        "string",
+		"pattern":     durationPattern,
+		"description": "Duration (ie 15s or 1m30s)",
+	},
+}
+
+// schemaFields adds keywords to individual fields.  Keys are the Go
+// type name and the JSON field name
+var schemaFields = map[string]jsonObject{
+	"Config.name":         {"pattern": namePattern, "description": "Title of the project"},
+	"Config.desc":         {"description": "Short description of the project"},
+	"Config.maintainer":   {"description": "Person responsible for the project"},
+	"Config.authors":      {"description": "Anyone else who has contributed to the project"},
+	"Config.org":          {"description": "Organization that owns the project"},
+	"Config.license":      {"description": "SPDX identifier of the project license"},
+	"Config.year":         {"description": "Copyright year in the license"},
+	"Config.url":          {"anyOf": emptyOr("uri"), "description": "Project webpage"},
+	"Config.mod":          {"description": "Go module information"},
+	"Config.controllers":  {"description": "Groups of endpoints served by the API"},
+	"Config.environments": {"description": "Places the API is deployed"},
+	"Config.features":     {"description": "Optional parts of the project that are enabled"},
+	"Maintainer.email":    {"anyOf": emptyOr("email")},
+	"Module.path":         {"description": "Go module path"},
+	"Controller.name":     {"pattern": namePattern},
+	"Controller.path":     {"pattern": "^/", "description": "Base path of the controller's endpoints"},
+	"Environment.name":    {"pattern": namePattern},
+	"Environment.baseURL": {"anyOf": emptyOr("uri"), "description": "URL clients use to reach the API"},
+}
+
+// emptyOr allows an optional field to either be empty or
+// match the format, since unset fields are written as ""
+func emptyOr(format string) []interface{} {
+	return []interface{}{jsonObject{"format": format}, jsonObject{"const": ""}}
+}
+
+// typeSchema builds the JSON Schema for a Go type
+func typeSchema(t reflect.Type) jsonObject {
+	if schema, found := schemaTypes[t]; found {
+		c := jsonObject{}
+		for key, value := range schema {
+			c[key] = value
+		}
+		return c
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return jsonObject{"type": "string"}
+	case reflect.Bool:
+		return jsonObject{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return jsonObject{"type": "integer"}
+	case reflect.Slice:
+		// nil slices are encoded as null
+		return jsonObject{"type": []interface{}{"array", "null"}, "items": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := jsonObject{}
+		for i := 0; i < t.NumField(); i++ {
+			name := fieldName(t.Field(i))
+			if name == "" {
+				continue
+			}
+
+			schema := typeSchema(t.Field(i).Type)
+			for key, value := range schemaFields[t.Name()+"."+name] {
+				schema[key] = value
+			}
+			properties[name] = schema
+		}
+		return jsonObject{"type": "object", "properties": properties, "additionalProperties": false}
+	}
+	return jsonObject{}
+}
+
+// ConfigSchema returns the JSON Schema for the project config file
+func ConfigSchema() jsonObject {
+	schema := typeSchema(reflect.TypeOf(Config{}))
+	schema["$schema"] = SchemaDialect
+	schema["title"] = "waffle project config"
+	return schema
+}
+
+// WriteConfigSchema writes the JSON Schema for the
+// project config file to filename
+func WriteConfigSchema(filename string) error {
+	content, err := json.MarshalIndent(ConfigSchema(), "", "  ")
+	if err == nil && !checking {
+		// nothing is written while checking
+		err = os.MkdirAll(filepath.Dir(filename), 0755)
+	}
+
+	if err == nil {
+		err = WriteFile(filename, append(content, '\n'))
+	}
+	return err
+}
+
+// schemaValidator checks a decoded JSON document against the subset
+// of JSON Schema that typeSchema generates
+type schemaValidator struct {
+	errors   ValidationError
+	patterns map[string]*regexp.Regexp
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case jsonObject:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func (sv *schemaValidator) match(pattern, str string) bool {
+	re, found := sv.patterns[pattern]
+	if !found {
+		re = regexp.MustCompile(pattern)
+		sv.patterns[pattern] = re
+	}
+	return re.MatchString(str)
+}
+
+func (sv *schemaValidator) validate(schema jsonObject, value interface{}, path string) {
+	field := path
+	if field == "" {
+		field = "/"
+	}
+
+	types := []string{}
+	switch t := schema["type"].(type) {
+	case string:
+		types = append(types, t)
+	case []interface{}:
+		for _, t := range t {
+			types = append(types, t.(string))
+		}
+	}
+
+	valueType := jsonType(value)
+	typeMatches := len(types) == 0
+	for _, t := range types {
+		typeMatches = typeMatches || t == valueType
+	}
+
+	if !typeMatches {
+		sv.errors.add(field, fmt.Errorf("expected %s but found %s", strings.Join(types, " or "), valueType))
+		return
+	}
+
+	switch value := value.(type) {
+	case string:
+		if pattern, ok := schema["pattern"].(string); ok && !sv.match(pattern, value) {
+			if desc, found := patternDescs[pattern]; found {
+				sv.errors.add(field, fmt.Errorf("%q is not %s", value, desc))
+			} else {
+				sv.errors.add(field, fmt.Errorf("%q does not match the pattern %s", value, pattern))
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(jsonObject); ok {
+			for i, item := range value {
+				sv.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case jsonObject:
+		properties, _ := schema["properties"].(jsonObject)
+		for _, key := range sortedKeys(value) {
+			name := strings.TrimPrefix(path+"."+key, ".")
+			if property, found := properties[key].(jsonObject); found {
+				sv.validate(property, value[key], name)
+			} else if schema["additionalProperties"] == false {
+				sv.errors.add(name, fmt.Errorf("%w, expected one of %s", ErrUnknownField, strings.Join(sortedKeys(properties), ", ")))
+			}
+		}
+	}
+}
+
+func sortedKeys(obj jsonObject) []string {
+	keys := []string{}
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateConfigSchema checks the content of a project config
+// file against the config schema
+func validateConfigSchema(content []byte) error {
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	err := dec.Decode(&value)
+	if err != nil {
+		return err
+	}
+
+	sv := &schemaValidator{patterns: make(map[string]*regexp.Regexp)}
+	sv.validate(ConfigSchema(), value, "")
+	if len(sv.errors) > 0 {
+		return sv.errors
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package waffle
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidateConfigSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"empty", `{}`, nil},
+		{
+			"valid",
+			`{
+				"$schema": "./.waffle/project.schema.json",
+				"name": "widgets",
+				"maintainer": {"name": "Tester", "email": "tester@example.com"},
+				"authors": null,
+				"year": 2021,
+				"url": "",
+				"mod": {"path": "example.com/widgets", "version": "v1.2.3-rc.1+build.5"},
+				"controllers": [{"name": "users", "path": "/users"}],
+				"environments": [{"name": "dev", "listenOn": [":8080"], "readTimeout": "1m30s"}],
+				"features": ["docker"]
+			}`,
+			nil,
+		},
+		{"unknown field", `{"nmae": "widgets"}`, []string{"nmae: unknown config field"}},
+		{"unknown nested field", `{"mod": {"versoin": "1.0.0"}}`, []string{"mod.versoin: unknown config field"}},
+		{"wrong type", `{"name": 1}`, []string{"name: expected string but found integer"}},
+		{"not an integer", `{"year": 20.5}`, []string{"year: expected integer but found number"}},
+		{"not an object", `[]`, []string{"/: expected object but found array"}},
+		{"not a list", `{"features": "docker"}`, []string{"features: expected array or null but found string"}},
+		{"list item", `{"features": ["docker", 1]}`, []string{"features[1]: expected string but found integer"}},
+		{"version", `{"mod": {"version": "1.0"}}`, []string{`mod.version: "1.0" is not a semantic version (ie 1.2.3 or 1.2.3-rc.1)`}},
+		{"duration", `{"environments": [{"writeTimeout": "15"}]}`, []string{`environments[0].writeTimeout: "15" is not a duration (ie 15s or 1m30s)`}},
+		{"name", `{"name": "1st"}`, []string{`name: "1st" is not a name that starts with a letter and contains only letters, digits, '.', '_' and '-'`}},
+		{"controller path", `{"controllers": [{"path": "users"}]}`, []string{`controllers[0].path: "users" is not a path that starts with a slash`}},
+		{
+			"several errors",
+			`{"year": "2021", "maintainer": {"nmae": "x"}, "mod": {"path": 1}}`,
+			[]string{"maintainer.nmae: unknown config field", "mod.path: expected string but found integer", "year: expected integer but found string"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateConfigSchema([]byte(test.content))
+			var got []string
+			if ve := (ValidationError{}); errors.As(err, &ve) {
+				for _, fe := range ve {
+					// leave off the list of expected fields
+					msg := fe.Error()
+					if errors.Is(fe, ErrUnknownField) {
+						msg = fe.Field + ": " + ErrUnknownField.Error()
+					}
+					got = append(got, msg)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("Wanted %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateConfigSchemaInvalidJSON(t *testing.T) {
+	err := validateConfigSchema([]byte(`{"name": `))
+	if ve := (ValidationError{}); err == nil || errors.As(err, &ve) {
+		t.Errorf("Expected a JSON syntax error got %v", err)
+	}
+}
+
+func TestWriteConfigSchemaCheck(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), filepath.FromSlash(DefSchemaFile))
+	files, err := CheckWrites(func() error { return WriteConfigSchema(filename) })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(files) != 1 || files[0] != filename {
+		t.Errorf("Wanted [%s] got %v", filename, files)
+	}
+
+	// nothing is written while checking
+	if _, err := os.Stat(filepath.Dir(filename)); !os.IsNotExist(err) {
+		t.Errorf("Expected %s not to exist got %v", filepath.Dir(filename), err)
+	}
+}
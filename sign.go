@@ -0,0 +1,366 @@
+package waffle
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	ErrNoSigningKey     = WaffleError("no signing key, use -sign-key or set git's user.signingKey to an OpenPGP or SSH key file")
+	ErrSigningFormat    = WaffleError("unsupported signing format, only OpenPGP and SSH keys can be used to sign tags")
+	ErrInvalidKeyFile   = WaffleError("key file does not contain an armored OpenPGP key or an SSH private key")
+	ErrUnsigned         = WaffleError("tag is not signed")
+	ErrUnknownSigner    = WaffleError("tag is signed by a key that is not in the keyring")
+	ErrNoAllowedSigners = WaffleError("no SSH allowed signers, use -keyring or set git's gpg.ssh.allowedSignersFile")
+
+	// PassphraseEnv is the environment variable the signing key
+	// passphrase is read from before prompting for it
+	PassphraseEnv = "WAFFLE_SIGNING_PASSPHRASE"
+)
+
+// SigningConfig is the tag signing configuration from git config
+type SigningConfig struct {
+	// KeyFile is user.signingKey
+	KeyFile string
+	// Format is gpg.format (ie openpgp or ssh)
+	Format string
+	// Sign is tag.gpgSign, tags are signed by default if it is true
+	Sign bool
+	// AllowedSignersFile is gpg.ssh.allowedSignersFile, the SSH keys
+	// that tags are verified with
+	AllowedSignersFile string
+}
+
+// SigningConfig reads the tag signing configuration from the repo,
+// global and system git config.  The first one to set an option wins
+func (gr *GitRepo) SigningConfig() (sc SigningConfig, err error) {
+	configs := []*gitconfig.Config{}
+	cfg, err := gr.repo.Config()
+	if err == nil {
+		configs = append(configs, cfg)
+	}
+
+	for _, scope := range []gitconfig.Scope{gitconfig.GlobalScope, gitconfig.SystemScope} {
+		if cfg, err1 := gitconfig.LoadConfig(scope); err1 == nil {
+			configs = append(configs, cfg)
+		}
+	}
+
+	sign := ""
+	for _, cfg := range configs {
+		if sc.KeyFile == "" {
+			sc.KeyFile = cfg.Raw.Section("user").Option("signingKey")
+		}
+
+		if sc.Format == "" {
+			sc.Format = cfg.Raw.Section("gpg").Option("format")
+		}
+
+		if sc.AllowedSignersFile == "" {
+			sc.AllowedSignersFile = cfg.Raw.Section("gpg").Subsection("ssh").Option("allowedSignersFile")
+		}
+
+		if sign == "" {
+			sign = cfg.Raw.Section("tag").Option("gpgSign")
+		}
+	}
+	sc.Sign = sign == "true"
+	return
+}
+
+// SigningKeyFile returns user.signingKey if it is a key file.  Git
+// also accepts a GPG key ID or a literal SSH key, but those keys are
+// then in gpg's keyring or ssh-agent which can't be used, so
+// ErrNoSigningKey is returned for anything that isn't an existing file
+func (sc SigningConfig) SigningKeyFile() (string, error) {
+	if sc.KeyFile == "" {
+		return "", ErrNoSigningKey
+	}
+
+	if info, err := os.Stat(keyFilename(sc.KeyFile)); err != nil || !info.Mode().IsRegular() {
+		if sc.Format == "ssh" {
+			return "", fmt.Errorf("%w (user.signingKey %q is not a file, set it to the path of the SSH key)", ErrNoSigningKey, sc.KeyFile)
+		}
+		return "", fmt.Errorf("%w (user.signingKey %q is not a file, GPG key IDs can't be used, export the key with \"gpg --armor --export-secret-keys %s\")", ErrNoSigningKey, sc.KeyFile, sc.KeyFile)
+	}
+	return sc.KeyFile, nil
+}
+
+// KeyringFile returns the file that tags are verified with.  SSH
+// signatures are verified with gpg.ssh.allowedSignersFile, OpenPGP
+// signatures with the public keys in the signing key file
+func (sc SigningConfig) KeyringFile() (string, error) {
+	if sc.Format != "ssh" {
+		return sc.SigningKeyFile()
+	}
+
+	if sc.AllowedSignersFile == "" {
+		return "", ErrNoAllowedSigners
+	}
+	return sc.AllowedSignersFile, nil
+}
+
+// keyFilename expands a leading ~ in filename to the home directory
+func keyFilename(filename string) string {
+	if strings.HasPrefix(filename, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			filename = filepath.Join(home, filename[2:])
+		}
+	}
+	return filename
+}
+
+// SigningKey is a private key that signs tags
+type SigningKey interface {
+	// Name identifies the key owner
+	Name() string
+
+	// sign returns the armored signature of message
+	sign(message []byte) (string, error)
+}
+
+type pgpKey struct {
+	entity *openpgp.Entity
+}
+
+func (pk pgpKey) Name() string { return pk.entity.PrimaryIdentity().Name }
+
+func (pk pgpKey) sign(message []byte) (string, error) {
+	builder := &strings.Builder{}
+	err := openpgp.ArmoredDetachSign(builder, pk.entity, bytes.NewReader(message), nil)
+	return builder.String(), err
+}
+
+type sshKey struct {
+	signer ssh.Signer
+}
+
+func (sk sshKey) Name() string { return ssh.FingerprintSHA256(sk.signer.PublicKey()) }
+
+func (sk sshKey) sign(message []byte) (string, error) { return sshSign(sk.signer, message) }
+
+// LoadSigningKey reads an armored OpenPGP private key or an SSH private
+// key from filename.  Git config usually names the public half of SSH
+// keys, so for a .pub file the private key next to it is read.  If the
+// key is encrypted the passphrase is read from WAFFLE_SIGNING_PASSPHRASE
+// or prompted for
+func LoadSigningKey(filename string) (SigningKey, error) {
+	filename = strings.TrimSuffix(keyFilename(filename), ".pub")
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Contains(content, []byte("-----BEGIN PGP")) {
+		return loadPGPKey(filename, content)
+	}
+	return loadSSHKey(filename, content)
+}
+
+func loadPGPKey(filename string, content []byte) (SigningKey, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(content))
+	if err != nil || len(entities) == 0 || entities[0].PrivateKey == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKeyFile, filename)
+	}
+
+	entity := entities[0]
+	if entity.PrivateKey.Encrypted {
+		passphrase, err := signingPassphrase(filename)
+		if err == nil {
+			err = entity.PrivateKey.Decrypt([]byte(passphrase))
+		}
+
+		for i := 0; err == nil && i < len(entity.Subkeys); i++ {
+			if key := entity.Subkeys[i].PrivateKey; key != nil && key.Encrypted {
+				err = key.Decrypt([]byte(passphrase))
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", filename, err)
+		}
+	}
+	return pgpKey{entity}, nil
+}
+
+func loadSSHKey(filename string, content []byte) (SigningKey, error) {
+	signer, err := ssh.ParsePrivateKey(content)
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		var passphrase string
+		passphrase, err = signingPassphrase(filename)
+		if err == nil {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(content, []byte(passphrase))
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", filename, err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKeyFile, filename)
+	}
+	return sshKey{signer}, nil
+}
+
+// signingPassphrase returns the passphrase for the key in filename
+func signingPassphrase(filename string) (string, error) {
+	passphrase := os.Getenv(PassphraseEnv)
+	if passphrase != "" {
+		return passphrase, nil
+	}
+
+	passphrase, err := PromptPassword(fmt.Sprintf("Passphrase for %s: ", filename))
+	if IsNoInput(err) {
+		err = fmt.Errorf("%w, set %s to the passphrase for %s", err, PassphraseEnv, filename)
+	}
+	return passphrase, err
+}
+
+// ReadKeyring reads a file of armored OpenPGP keys or SSH allowed
+// signers to verify tags with
+func ReadKeyring(filename string) (string, error) {
+	content, err := ioutil.ReadFile(keyFilename(filename))
+	return string(content), err
+}
+
+// SetSigningKey signs the tags created by Tag and TagAs with key.  Tags
+// are not signed if key is nil
+func (gr *GitRepo) SetSigningKey(key SigningKey) {
+	gr.signKey = key
+}
+
+// signedTag creates a tag of hash that is signed with the signing key.
+// go-git can only sign with OpenPGP keys, so the tag object is built
+// here the same way git does: the signature of the encoded tag is
+// appended to the message
+func (gr *GitRepo) signedTag(name string, hash plumbing.Hash, opts *git.CreateTagOptions) error {
+	refName := plumbing.NewTagReferenceName(name)
+	_, err := gr.repo.Reference(refName, false)
+	if err == nil {
+		return git.ErrTagExists
+	} else if err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	err = opts.Validate(gr.repo, hash)
+	if err != nil {
+		return err
+	}
+
+	tag := &object.Tag{
+		Name:       name,
+		Tagger:     *opts.Tagger,
+		Message:    opts.Message,
+		TargetType: plumbing.CommitObject,
+		Target:     hash,
+	}
+
+	var payload []byte
+	obj := gr.repo.Storer.NewEncodedObject()
+	err = tag.EncodeWithoutSignature(obj)
+	if err == nil {
+		payload, err = readObject(obj)
+	}
+
+	if err == nil {
+		tag.PGPSignature, err = gr.signKey.sign(payload)
+	}
+
+	obj = gr.repo.Storer.NewEncodedObject()
+	if err == nil {
+		err = tag.Encode(obj)
+	}
+
+	if err == nil {
+		hash, err = gr.repo.Storer.SetEncodedObject(obj)
+	}
+
+	if err == nil {
+		err = gr.repo.Storer.SetReference(plumbing.NewHashReference(refName, hash))
+	}
+	return err
+}
+
+func readObject(obj plumbing.EncodedObject) ([]byte, error) {
+	reader, err := obj.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// VerifyRelease checks the signature of a release tag against the keys
+// in keyring and returns the name of the key that signed it.  OpenPGP
+// signatures are checked against armored OpenPGP keys and SSH signatures
+// against an allowed signers file.  Lightweight tags and tags without a
+// signature return ErrUnsigned
+func (gr *GitRepo) VerifyRelease(release Release, keyring string) (string, error) {
+	ref, err := gr.repo.Tag(release.Tag)
+	if err != nil {
+		return "", err
+	}
+
+	tag, err := gr.repo.TagObject(ref.Hash())
+	if err == plumbing.ErrObjectNotFound {
+		return "", ErrUnsigned
+	} else if err != nil {
+		return "", err
+	}
+
+	if tag.PGPSignature != "" {
+		entity, err := tag.Verify(keyring)
+		if err != nil {
+			return "", err
+		}
+		return entity.PrimaryIdentity().Name, nil
+	}
+
+	// go-git only splits off OpenPGP signatures, so SSH
+	// signatures are still at the end of the message
+	i := strings.Index(tag.Message, sshsigBegin)
+	if i < 0 || (i > 0 && tag.Message[i-1] != '\n') {
+		return "", ErrUnsigned
+	}
+	return verifySSHTag(tag, keyring, i)
+}
+
+func verifySSHTag(tag *object.Tag, keyring string, i int) (string, error) {
+	signature := tag.Message[i:]
+	unsigned := *tag
+	unsigned.Message = tag.Message[:i]
+
+	var payload []byte
+	obj := &plumbing.MemoryObject{}
+	err := unsigned.EncodeWithoutSignature(obj)
+	if err == nil {
+		payload, err = readObject(obj)
+	}
+
+	var key ssh.PublicKey
+	if err == nil {
+		key, err = sshVerify(payload, signature)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	for _, signer := range parseSSHSigners(keyring) {
+		if bytes.Equal(signer.key.Marshal(), key.Marshal()) {
+			return signer.name, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownSigner, ssh.FingerprintSHA256(key))
+}
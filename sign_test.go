@@ -0,0 +1,62 @@
+package waffle
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestVerifyRelease(t *testing.T) {
+	signer := testSSHSigner(t, "ed25519")
+	other := testSSHSigner(t, "ed25519")
+	allowed := func(name string, key ssh.PublicKey) string {
+		return fmt.Sprintf("%s %s", name, ssh.MarshalAuthorizedKey(key))
+	}
+
+	tests := []struct {
+		name    string
+		key     SigningKey
+		keyring string
+		want    string
+		wantErr error
+	}{
+		{"signed", sshKey{signer}, allowed("dev@example.com", signer.PublicKey()), "dev@example.com", nil},
+		{"unknown signer", sshKey{signer}, allowed("other@example.com", other.PublicKey()), "", ErrUnknownSigner},
+		{"unsigned", nil, allowed("dev@example.com", signer.PublicKey()), "", ErrUnsigned},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := initTestRepo(t)
+			repo.SetSigningKey(test.key)
+			if err := repo.TagAs("v1.0.0", "Release v1.0.0", testMaintainer); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			got, err := repo.VerifyRelease(Release{Tag: "v1.0.0"}, test.keyring)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Wanted error %v got %v", test.wantErr, err)
+			}
+
+			if got != test.want {
+				t.Errorf("Wanted %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSignedTagExists(t *testing.T) {
+	repo := initTestRepo(t)
+	repo.SetSigningKey(sshKey{testSSHSigner(t, "ed25519")})
+	err := repo.TagAs("v1.0.0", "Release v1.0.0", testMaintainer)
+	if err == nil {
+		err = repo.TagAs("v1.0.0", "Release v1.0.0", testMaintainer)
+	}
+
+	if err != git.ErrTagExists {
+		t.Errorf("Wanted %v got %v", git.ErrTagExists, err)
+	}
+}
@@ -0,0 +1,194 @@
+package waffle
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSH signatures use the format that "ssh-keygen -Y sign" creates and
+// git uses for gpg.format=ssh, see PROTOCOL.sshsig in the OpenSSH source
+const (
+	ErrInvalidSSHSignature = WaffleError("invalid SSH signature")
+
+	sshsigMagic     = "SSHSIG"
+	sshsigVersion   = 1
+	sshsigNamespace = "git"
+	sshsigBegin     = "-----BEGIN SSH SIGNATURE-----"
+	sshsigEnd       = "-----END SSH SIGNATURE-----"
+)
+
+// sshsigSignedData is the data that the key signs
+type sshsigSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// sshsigBlob is the signature that is armored
+type sshsigBlob struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+func sshsigHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "sha512":
+		return sha512.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	}
+	return nil, fmt.Errorf("%w: unsupported hash algorithm %q", ErrInvalidSSHSignature, algorithm)
+}
+
+// sshsigSigned returns the data that is signed for message
+func sshsigSigned(algorithm string, message []byte) ([]byte, error) {
+	h, err := sshsigHash(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Write(message)
+	data := ssh.Marshal(sshsigSignedData{
+		Namespace:     sshsigNamespace,
+		HashAlgorithm: algorithm,
+		Hash:          h.Sum(nil),
+	})
+	return append([]byte(sshsigMagic), data...), nil
+}
+
+// sshSign returns the armored SSH signature of message
+func sshSign(signer ssh.Signer, message []byte) (string, error) {
+	data, err := sshsigSigned("sha512", message)
+	if err != nil {
+		return "", err
+	}
+
+	// RSA keys must use SHA-2, ssh-keygen rejects SHA-1 signatures
+	var sig *ssh.Signature
+	if as, ok := signer.(ssh.AlgorithmSigner); ok && signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		sig, err = as.SignWithAlgorithm(rand.Reader, data, ssh.SigAlgoRSASHA2512)
+	} else {
+		sig, err = signer.Sign(rand.Reader, data)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	blob := append([]byte(sshsigMagic), ssh.Marshal(sshsigBlob{
+		Version:       sshsigVersion,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     sshsigNamespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh.Marshal(sig),
+	})...)
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	armored := &strings.Builder{}
+	armored.WriteString(sshsigBegin + "\n")
+	for len(encoded) > 70 {
+		armored.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	armored.WriteString(encoded + "\n" + sshsigEnd + "\n")
+	return armored.String(), nil
+}
+
+// sshVerify checks the armored SSH signature of message and
+// returns the public key that made it
+func sshVerify(message []byte, armored string) (ssh.PublicKey, error) {
+	armored = strings.TrimSpace(armored)
+	if !strings.HasPrefix(armored, sshsigBegin) || !strings.HasSuffix(armored, sshsigEnd) {
+		return nil, fmt.Errorf("%w: missing armor", ErrInvalidSSHSignature)
+	}
+
+	encoded := strings.Join(strings.Fields(armored[len(sshsigBegin):len(armored)-len(sshsigEnd)]), "")
+	content, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || !bytes.HasPrefix(content, []byte(sshsigMagic)) {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidSSHSignature)
+	}
+
+	var blob sshsigBlob
+	var sig ssh.Signature
+	var key ssh.PublicKey
+	err = ssh.Unmarshal(content[len(sshsigMagic):], &blob)
+	if err == nil {
+		err = ssh.Unmarshal(blob.Signature, &sig)
+	}
+
+	if err == nil {
+		key, err = ssh.ParsePublicKey(blob.PublicKey)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSSHSignature, err)
+	} else if blob.Version != sshsigVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidSSHSignature, blob.Version)
+	} else if blob.Namespace != sshsigNamespace {
+		return nil, fmt.Errorf("%w: namespace is %q instead of %q", ErrInvalidSSHSignature, blob.Namespace, sshsigNamespace)
+	}
+
+	data, err := sshsigSigned(blob.HashAlgorithm, message)
+	if err == nil {
+		err = key.Verify(data, &sig)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSSHSignature, err)
+	}
+	return key, nil
+}
+
+// sshSigner is a public key that is allowed to sign tags
+type sshSigner struct {
+	name string
+	key  ssh.PublicKey
+}
+
+// parseSSHSigners reads the public keys in an allowed signers file
+// ("principal [options] key-type key") or an authorized keys or .pub
+// file ("key-type key [comment]").  The principal or comment names the
+// signer, otherwise the key fingerprint is used
+func parseSSHSigners(content string) (signers []sshSigner) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		for i := 0; i+1 < len(fields); i++ {
+			raw, err := base64.StdEncoding.DecodeString(fields[i+1])
+			if err != nil {
+				continue
+			}
+
+			key, err := ssh.ParsePublicKey(raw)
+			if err != nil || key.Type() != fields[i] {
+				continue
+			}
+
+			signer := sshSigner{name: ssh.FingerprintSHA256(key), key: key}
+			if i > 0 {
+				signer.name = fields[0]
+			} else if i+2 < len(fields) {
+				signer.name = strings.Join(fields[i+2:], " ")
+			}
+			signers = append(signers, signer)
+			break
+		}
+	}
+	return signers
+}
@@ -0,0 +1,121 @@
+package waffle
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func testSSHSigner(t *testing.T, keyType string) ssh.Signer {
+	t.Helper()
+	var key interface{}
+	var err error
+	if keyType == "rsa" {
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	} else {
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	}
+
+	var signer ssh.Signer
+	if err == nil {
+		signer, err = ssh.NewSignerFromKey(key)
+	}
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return signer
+}
+
+func TestSSHSignature(t *testing.T) {
+	for _, keyType := range []string{"ed25519", "rsa"} {
+		t.Run(keyType, func(t *testing.T) {
+			signer := testSSHSigner(t, keyType)
+			message := []byte("object 0123\ntype commit\ntag v1.0.0\n\nRelease v1.0.0\n")
+			armored, err := sshSign(signer, message)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !strings.HasPrefix(armored, sshsigBegin+"\n") || !strings.HasSuffix(armored, sshsigEnd+"\n") {
+				t.Errorf("Expected an armored signature got %q", armored)
+			}
+
+			key, err := sshVerify(message, armored)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got, want := ssh.FingerprintSHA256(key), ssh.FingerprintSHA256(signer.PublicKey()); got != want {
+				t.Errorf("Wanted %v got %v", want, got)
+			}
+
+			if _, err = sshVerify(append(message, 'x'), armored); !errors.Is(err, ErrInvalidSSHSignature) {
+				t.Errorf("Wanted %v got %v", ErrInvalidSSHSignature, err)
+			}
+		})
+	}
+}
+
+func TestSSHVerifyMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		armored string
+	}{
+		{"empty", ""},
+		{"no armor", "U1NIU0lH"},
+		{"bad base64", sshsigBegin + "\n!!!\n" + sshsigEnd},
+		{"bad magic", sshsigBegin + "\nU1NIU0lI\n" + sshsigEnd},
+		{"truncated", sshsigBegin + "\nU1NIU0lHAAAAAQ==\n" + sshsigEnd},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := sshVerify([]byte("message"), test.armored); !errors.Is(err, ErrInvalidSSHSignature) {
+				t.Errorf("Wanted %v got %v", ErrInvalidSSHSignature, err)
+			}
+		})
+	}
+}
+
+func TestParseSSHSigners(t *testing.T) {
+	key := testSSHSigner(t, "ed25519").PublicKey()
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+	fingerprint := ssh.FingerprintSHA256(key)
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"allowed signers", fmt.Sprintf("dev@example.com %s\n", authorized), []string{"dev@example.com"}},
+		{"allowed signers with options", fmt.Sprintf("dev@example.com namespaces=\"git\" %s\n", authorized), []string{"dev@example.com"}},
+		{"public key with comment", fmt.Sprintf("%s dev laptop\n", authorized), []string{"dev laptop"}},
+		{"public key", authorized, []string{fingerprint}},
+		{"comments and blank lines", fmt.Sprintf("# signers\n\n%s\n", authorized), []string{fingerprint}},
+		{"invalid key", "dev@example.com ssh-ed25519 AAAA\n", nil},
+		{"mismatched type", fmt.Sprintf("ssh-rsa %s\n", strings.Fields(authorized)[1]), nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, signer := range parseSSHSigners(test.content) {
+				got = append(got, signer.name)
+				if signer.key.Type() != key.Type() {
+					t.Errorf("Wanted %v got %v", key.Type(), signer.key.Type())
+				}
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("Wanted %v got %v", test.want, got)
+			}
+		})
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"go/format"
 	"io/fs"
-	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -17,6 +16,9 @@ import (
 //go:embed internal/templates
 var internal embed.FS
 
+// errEmptyTemplate indicates that a template produced no output
+const errEmptyTemplate = WaffleError("template output is empty")
+
 func getTmplName(filename string) string {
 	dir, tmplName := path.Split("/" + filename)
 	if strings.HasPrefix(tmplName, "!") {
@@ -31,6 +33,21 @@ type templateBuilder struct {
 	dest      string
 	root      *template.Template
 	templates []string
+
+	// expanded are the templates whose names begin with a
+	// placeholder ($).  These are executed once for each item
+	// the placeholder refers to
+	expanded []string
+
+	// conflicts are the files that could not be merged
+	// cleanly with local changes
+	conflicts []string
+}
+
+// controllerData is passed to the $controller templates
+type controllerData struct {
+	*Config
+	Controller Controller
 }
 
 func newTemplateBuilder(input fs.FS, dest string) (*templateBuilder, error) {
@@ -56,7 +73,9 @@ func (tb *templateBuilder) init() error {
 		if strings.HasSuffix(filename, ".tmpl") {
 			tmplName := getTmplName(filename)
 			subTmpl := tb.root.New(tmplName)
-			if !strings.HasPrefix(d.Name(), "$") {
+			if strings.HasPrefix(d.Name(), "$") {
+				tb.expanded = append(tb.expanded, tmplName)
+			} else {
 				tb.templates = append(tb.templates, tmplName)
 			}
 
@@ -75,55 +94,106 @@ func (tb *templateBuilder) init() error {
 	})
 }
 
-func (tb *templateBuilder) executeTemplate(buf *bytes.Buffer, name string, config Config) error {
+// executeTemplate generates the file and merges it with any local changes.  The
+// number of merge conflicts is returned
+func (tb *templateBuilder) executeTemplate(buf *bytes.Buffer, name, filename string, data interface{}) (conflicts int, err error) {
 	defer buf.Reset()
-	err := tb.root.ExecuteTemplate(buf, name, config)
-	if err == nil {
-		dest := filepath.Join(tb.dest, filepath.FromSlash(name))
-		err = os.MkdirAll(filepath.Dir(dest), 0755)
+	err = tb.root.ExecuteTemplate(buf, name, data)
+	if err == nil && len(bytes.TrimSpace(buf.Bytes())) == 0 {
+		// placeholder templates don't create empty files
+		return 0, errEmptyTemplate
+	} else if err == nil {
+		dest := filepath.Join(tb.dest, filepath.FromSlash(filename))
+		if !checking {
+			// nothing is written while checking
+			err = os.MkdirAll(filepath.Dir(dest), 0755)
+			if err == nil {
+				err = os.MkdirAll(filepath.Dir(generatedFile(tb.dest, filename)), 0755)
+			}
+		}
 
-		if err == nil && strings.HasSuffix(name, ".go") {
+		if err == nil {
 			b := buf.Bytes()
-			if b, err = format.Source(buf.Bytes()); err != nil {
-				// still write the file contents, but report the
-				// formatting error
-				err = fmt.Errorf("failed to format go source file %s: %w", dest, err)
-				b = buf.Bytes()
+			if strings.HasSuffix(filename, ".go") {
+				if b, err = format.Source(buf.Bytes()); err != nil {
+					// still write the file contents, but report the
+					// formatting error
+					err = fmt.Errorf("failed to format go source file %s: %w", dest, err)
+					b = buf.Bytes()
+				}
 			}
 
-			err1 := ioutil.WriteFile(dest, b, 0644)
-			if err == nil && err1 != nil {
-				err = fmt.Errorf("failed to write file %s: %w", dest, err)
+			merged, n, err1 := mergeGenerated(tb.dest, filename, b)
+			if err1 == nil {
+				conflicts = n
+				// the generated content is kept so that the next
+				// generation can be merged with any local changes
+				err1 = writeFiles(fileContent{dest, merged}, fileContent{generatedFile(tb.dest, filename), b})
+			} else {
+				err1 = fmt.Errorf("failed to merge file %s: %w", dest, err1)
 			}
-		} else if err != nil {
+
+			if err == nil {
+				err = err1
+			}
+		} else {
 			err = fmt.Errorf("failed to create directory %s: %w", filepath.Dir(dest), err)
 		}
 
 	} else {
 		err = fmt.Errorf("failed to execute template %s: %v", name, err)
 	}
+	return conflicts, err
+}
+
+func (tb *templateBuilder) run(buf *bytes.Buffer, name, filename string, data interface{}) error {
+	filename = strings.TrimPrefix(filename, "/")
+	conflicts, err := tb.executeTemplate(buf, name, filename, data)
+	if err == errEmptyTemplate {
+		err = nil
+	} else if err != nil {
+		Logger.Logf("<fail>%s</fail>: %v", filename, err)
+	} else if conflicts > 0 {
+		Logger.Logf("<warn>%s</warn>: %d conflict(s) with local changes", filename, conflicts)
+		tb.conflicts = append(tb.conflicts, filename)
+	} else if !checking {
+		Logger.Logf("<success>%s</success>", filename)
+	}
 	return err
 }
 
 func (tb *templateBuilder) execute(config Config) error {
-	buf := bytes.NewBuffer(make([]byte, 8192))
+	buf := bytes.NewBuffer(make([]byte, 0, 8192))
 	for _, tmplName := range tb.templates {
-		err := tb.executeTemplate(buf, tmplName, config)
-
-		if err == nil {
-			Logger.Logf("<success>%s</success>", tmplName)
-		} else {
-			Logger.Logf("<fail>%s</fail>: %v", tmplName, err)
+		if err := tb.run(buf, tmplName, tmplName, &config); err != nil {
 			return err
 		}
 	}
+
+	for _, tmplName := range tb.expanded {
+		if !strings.Contains(tmplName, "$controller") {
+			continue
+		}
+
+		for _, ctrl := range config.Controllers {
+			filename := strings.Replace(tmplName, "$controller", ctrl.FileName(), 1)
+			if err := tb.run(buf, tmplName, filename, controllerData{Config: &config, Controller: ctrl}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(tb.conflicts) > 0 {
+		return fmt.Errorf("%w in %s, resolve the conflict markers", ErrMergeConflict, strings.Join(tb.conflicts, ", "))
+	}
 	return nil
 }
 
 func ExecuteTemplates(srcDir string, destDir string, config Config) error {
 	templates, err := fs.Sub(internal, fmt.Sprintf("internal/templates/%s", srcDir))
 	if err == nil {
-		tb, err := newTemplateBuilder(templates, destDir)
+		var tb *templateBuilder
+		tb, err = newTemplateBuilder(templates, destDir)
 		if err == nil {
 			err = tb.execute(config)
 		}
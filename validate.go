@@ -0,0 +1,234 @@
+package waffle
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefGoModFile is the filename of the go module file
+	DefGoModFile = "go.mod"
+
+	ErrRequired = WaffleError("value is required")
+)
+
+// FieldError indicates that a single config field is invalid
+type FieldError struct {
+	// Field is the JSON path of the field (ie maintainer.email)
+	Field string
+	// Err is the reason the field is invalid
+	Err error
+}
+
+func (fe FieldError) Error() string { return fmt.Sprintf("%s: %v", fe.Field, fe.Err) }
+
+func (fe FieldError) Unwrap() error { return fe.Err }
+
+// ValidationError is the list of all the invalid fields
+// found when validating a config
+type ValidationError []FieldError
+
+func (ve ValidationError) Error() string {
+	builder := &strings.Builder{}
+	builder.WriteString("invalid project config:")
+	for _, fe := range ve {
+		fmt.Fprintf(builder, "\n    %v", fe)
+	}
+	return builder.String()
+}
+
+func (ve *ValidationError) add(field string, err error) {
+	if err != nil {
+		*ve = append(*ve, FieldError{Field: field, Err: err})
+	}
+}
+
+// Validate checks each of the config fields and returns a
+// ValidationError listing every field that is invalid.  The
+// module path is compared to the go.mod file in the project
+// root if one exists
+func (c *Config) Validate() error {
+	return c.validate(c.Dir())
+}
+
+func (c *Config) validate(dir string) error {
+	ve := ValidationError{}
+	ve.add("name", CheckName(c.Name))
+	ve.add("url", CheckURL(c.URL))
+	ve.add("maintainer.email", CheckEmail(c.Maintainer.Email))
+	for i, author := range c.Authors {
+		ve.add(fmt.Sprintf("authors[%d].email", i), CheckEmail(author.Email))
+	}
+	ve.add("license", CheckLicense(c.License))
+	for i, feature := range c.Features {
+		ve.add(fmt.Sprintf("features[%d]", i), CheckFeature(feature))
+	}
+	for i, ctrl := range c.Controllers {
+		nameErr := CheckName(ctrl.Name)
+		pathErr := CheckControllerPath(ctrl.Path)
+		for _, prev := range c.Controllers[:i] {
+			if nameErr == nil && prev.collides(ctrl) {
+				nameErr = fmt.Errorf("%w: %q", ErrControllerExists, prev.Name)
+			}
+
+			if pathErr == nil && pathsOverlap(prev.Path, ctrl.Path) {
+				pathErr = fmt.Errorf("%w: %q overlaps %q (%s)", ErrControllerOverlap, ctrl.Path, prev.Path, prev.Name)
+			}
+		}
+		ve.add(fmt.Sprintf("controllers[%d].name", i), nameErr)
+		ve.add(fmt.Sprintf("controllers[%d].path", i), pathErr)
+	}
+
+	for i, env := range c.Environments {
+		field := fmt.Sprintf("environments[%d]", i)
+		env.validate(&ve, field)
+		for _, prev := range c.Environments[:i] {
+			if prev.Name == env.Name {
+				ve.add(field+".name", fmt.Errorf("%w: %q", ErrEnvironmentExists, env.Name))
+			}
+		}
+	}
+
+	err := CheckModulePath(c.Module.Path)
+	if err == nil {
+		var modPath string
+		modPath, err = readModulePath(filepath.Join(dir, DefGoModFile))
+		if err == nil && modPath != c.Module.Path {
+			err = fmt.Errorf("%q does not match %q in %s, change one of them so they match", c.Module.Path, modPath, DefGoModFile)
+		} else if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	}
+	ve.add("mod.path", err)
+
+	if len(ve) > 0 {
+		return ve
+	}
+	return nil
+}
+
+// CheckName makes sure the project name is a simple identifier
+// that starts with a letter and only contains letters, digits,
+// dots, underscores and hyphens
+func CheckName(name string) error {
+	if name == "" {
+		return ErrRequired
+	}
+
+	for i, r := range name {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && ('0' <= r && r <= '9' || r == '.' || r == '_' || r == '-'):
+		default:
+			return fmt.Errorf("invalid character %q in %q, names must start with a letter and contain only letters, digits, '.', '_' and '-'", r, name)
+		}
+	}
+	return nil
+}
+
+// CheckURL makes sure that, if set, the URL is an absolute
+// http or https URL
+func CheckURL(str string) error {
+	if str == "" {
+		return nil
+	}
+
+	u, err := url.Parse(str)
+	if err == nil {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			err = fmt.Errorf("%q must be an http or https URL", str)
+		} else if u.Host == "" {
+			err = fmt.Errorf("%q is missing a host name", str)
+		}
+	}
+	return err
+}
+
+// CheckEmail makes sure that, if set, the email is a
+// plain address such as user@example.com
+func CheckEmail(str string) error {
+	if str == "" {
+		return nil
+	}
+
+	addr, err := mail.ParseAddress(str)
+	if err != nil {
+		err = fmt.Errorf("%q is not a valid email address: %v", str, err)
+	} else if addr.Address != str {
+		err = fmt.Errorf("%q is not a valid email address", str)
+	}
+	return err
+}
+
+// CheckModulePath validates the path according to the
+// rules the go command uses for module paths
+func CheckModulePath(path string) error {
+	if path == "" {
+		return ErrRequired
+	}
+
+	if strings.HasPrefix(path, "/") || strings.HasSuffix(path, "/") {
+		return fmt.Errorf("module path %q must not begin or end with a slash", path)
+	}
+
+	elems := strings.Split(path, "/")
+	if err := checkModuleDomain(elems[0]); err != nil {
+		return fmt.Errorf("malformed module path %q: %v", path, err)
+	}
+
+	for _, elem := range elems {
+		if err := checkPathElem(elem); err != nil {
+			return fmt.Errorf("malformed module path %q: %v", path, err)
+		}
+	}
+
+	if last := elems[len(elems)-1]; len(elems) > 1 && len(last) > 1 && last[0] == 'v' {
+		if n, err := strconv.Atoi(last[1:]); err == nil && (n < 2 || last[1] == '0') {
+			return fmt.Errorf("malformed module path %q: invalid major version suffix %q", path, last)
+		}
+	}
+	return nil
+}
+
+// checkModuleDomain makes sure the first path element looks like
+// a domain name: lower case letters, digits, dots and hyphens with
+// at least one dot
+func checkModuleDomain(elem string) error {
+	if !strings.Contains(elem, ".") {
+		return fmt.Errorf("missing dot in first path element %q", elem)
+	}
+
+	if strings.HasPrefix(elem, "-") {
+		return fmt.Errorf("leading dash in first path element %q", elem)
+	}
+
+	for _, r := range elem {
+		if !('a' <= r && r <= 'z' || '0' <= r && r <= '9' || r == '.' || r == '-') {
+			return fmt.Errorf("invalid character %q in first path element %q", r, elem)
+		}
+	}
+	return nil
+}
+
+func checkPathElem(elem string) error {
+	if elem == "" {
+		return fmt.Errorf("empty path element")
+	}
+
+	if elem[0] == '.' || elem[len(elem)-1] == '.' {
+		return fmt.Errorf("path element %q must not begin or end with a dot", elem)
+	}
+
+	for _, r := range elem {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || strings.ContainsRune("-._~", r)) {
+			return fmt.Errorf("invalid character %q in path element %q", r, elem)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package waffle
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckModulePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr string
+	}{
+		{path: "example.com/widgets"},
+		{path: "github.com/abates/waffle"},
+		{path: "example.com"},
+		{path: "my-host.example.com/a_b/c.d/e~f"},
+		{path: "example.com/widgets/v2"},
+		{path: "example.com/widgets/v10"},
+		{path: "example.com/v"},
+		{path: "example.com/version"},
+
+		{path: "", wantErr: ErrRequired.Error()},
+		{path: "/example.com/widgets", wantErr: "must not begin or end with a slash"},
+		{path: "example.com/widgets/", wantErr: "must not begin or end with a slash"},
+		{path: "widgets", wantErr: "missing dot in first path element"},
+		{path: "Example.com/widgets", wantErr: "invalid character 'E' in first path element"},
+		{path: "-example.com/widgets", wantErr: "leading dash in first path element"},
+		{path: "example.com//widgets", wantErr: "empty path element"},
+		{path: "example.com/.widgets", wantErr: "must not begin or end with a dot"},
+		{path: "example.com/widgets.", wantErr: "must not begin or end with a dot"},
+		{path: "example.com/wid gets", wantErr: "invalid character ' ' in path element"},
+		{path: "example.com/widgets/v1", wantErr: "invalid major version suffix"},
+		{path: "example.com/widgets/v0", wantErr: "invalid major version suffix"},
+		{path: "example.com/widgets/v02", wantErr: "invalid major version suffix"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			err := CheckModulePath(test.path)
+			if test.wantErr == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			} else if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Errorf("Wanted error containing %q got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateModulePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		goMod   string
+		wantErr string
+	}{
+		{name: "no go.mod", path: "example.com/widgets"},
+		{name: "matching go.mod", path: "example.com/widgets", goMod: "module example.com/widgets\n"},
+		{name: "mismatched go.mod", path: "example.com/widgets", goMod: "module example.com/gadgets\n", wantErr: `"example.com/widgets" does not match "example.com/gadgets" in go.mod`},
+		{name: "invalid go.mod", path: "example.com/widgets", goMod: "go 1.17\n", wantErr: "does not have a module directive"},
+		{name: "invalid path", path: "widgets", goMod: "module widgets\n", wantErr: "missing dot in first path element"},
+		{name: "missing path", goMod: "module example.com/widgets\n", wantErr: ErrRequired.Error()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if test.goMod != "" {
+				writeTestFile(t, filepath.Join(dir, DefGoModFile), test.goMod)
+			}
+
+			c := &Config{Name: "widgets", Module: Module{Path: test.path}}
+			err := c.validate(dir)
+			var modErr error
+			var ve ValidationError
+			if errors.As(err, &ve) {
+				for _, fe := range ve {
+					if fe.Field == "mod.path" {
+						modErr = fe.Err
+					} else {
+						t.Errorf("Unexpected error: %v", fe)
+					}
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if test.wantErr == "" && modErr != nil {
+				t.Errorf("Unexpected error: %v", modErr)
+			} else if test.wantErr != "" && (modErr == nil || !strings.Contains(modErr.Error(), test.wantErr)) {
+				t.Errorf("Wanted error containing %q got %v", test.wantErr, modErr)
+			}
+		})
+	}
+}
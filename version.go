@@ -0,0 +1,484 @@
+package waffle
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	ErrInvalidVersion    = WaffleError("invalid semantic version")
+	ErrInvalidConstraint = WaffleError("invalid version constraint")
+	ErrInvalidBump       = WaffleError("invalid version bump")
+)
+
+// VersionList is a list that is sortable
+// by semantic naming
+type VersionList []Version
+
+// Len of the version list.  This satisfies one of the
+// requirements of the sort.Interface interface
+func (vl VersionList) Len() int { return len(vl) }
+
+// Less determines if version[i] has a lower precedence than
+// version[j] according to the semantic versioning 2.0 rules.
+// This satisfies one of the requirements of the sort.Interface
+// interface
+func (vl VersionList) Less(i, j int) bool { return vl[i].Compare(vl[j]) < 0 }
+
+// Swap will swap the versions at indices i and j.  This
+// satisfies one of the requirements of the sort.Interface interface
+func (vl VersionList) Swap(i, j int) { vl[i], vl[j] = vl[j], vl[i] }
+
+// Version is a struct representation of a semantic version
+// as described at https://semver.org/spec/v2.0.0.html
+type Version struct {
+	// Major release number
+	Major int
+	// Minor release number
+	Minor int
+	// Patch release number
+	Patch int
+	// PreRelease is the dot separated list of pre-release
+	// identifiers (ie "rc.1") without the leading hyphen
+	PreRelease string
+	// Build is the dot separated build metadata (ie "20211006.sha.5114f85")
+	// without the leading plus sign.  Build metadata is ignored when
+	// determining precedence
+	Build string
+}
+
+// String will convert the Version so a string in the form
+// Major.Minor.Patch[-PreRelease][+Build] ie 1.0.0 or 1.0.0-rc.1
+func (v *Version) String() string {
+	str := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		str = fmt.Sprintf("%s-%s", str, v.PreRelease)
+	}
+
+	if v.Build != "" {
+		str = fmt.Sprintf("%s+%s", str, v.Build)
+	}
+	return str
+}
+
+// Tag returns the version in the form expected by Go modules
+// for repository tags (ie v1.0.0)
+func (v *Version) Tag() string {
+	return "v" + v.String()
+}
+
+// IsPreRelease indicates whether the version has pre-release identifiers
+func (v *Version) IsPreRelease() bool {
+	return v.PreRelease != ""
+}
+
+// Compare returns -1, 0 or 1 depending on whether v has a lower,
+// equal or higher precedence than other.  Build metadata is not
+// considered
+func (v Version) Compare(other Version) int {
+	if c := compareInt(v.Major, other.Major); c != 0 {
+		return c
+	}
+
+	if c := compareInt(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+
+	if c := compareInt(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+
+	return comparePreRelease(v.PreRelease, other.PreRelease)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePreRelease compares two pre-release strings.  A version
+// without a pre-release has a higher precedence than one with a
+// pre-release.  Otherwise each dot separated identifier is compared
+// from left to right: numeric identifiers are compared numerically,
+// alphanumeric identifiers lexically and numeric identifiers always
+// have lower precedence than alphanumeric ones.  A larger set of
+// identifiers has a higher precedence if all preceding identifiers
+// are equal
+func comparePreRelease(a, b string) int {
+	if a == b {
+		return 0
+	} else if a == "" {
+		return 1
+	} else if b == "" {
+		return -1
+	}
+
+	aIDs := strings.Split(a, ".")
+	bIDs := strings.Split(b, ".")
+	for i := 0; i < len(aIDs) && i < len(bIDs); i++ {
+		aNum, aErr := strconv.ParseUint(aIDs[i], 10, 64)
+		bNum, bErr := strconv.ParseUint(bIDs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNum < bNum {
+				return -1
+			} else if aNum > bNum {
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(aIDs[i], bIDs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(len(aIDs), len(bIDs))
+}
+
+// parseVersion parses up to three numeric components followed
+// by optional pre-release and build strings.  The number of
+// numeric components found is returned so that constraints can
+// treat partial versions (ie "1.2") as ranges.  An optional "v"
+// prefix is accepted.
+func parseVersion(str string) (v Version, parts int, err error) {
+	input := str
+	str = strings.TrimPrefix(str, "v")
+	if i := strings.Index(str, "+"); i >= 0 {
+		v.Build = str[i+1:]
+		str = str[:i]
+		err = checkIdentifiers(v.Build, false)
+	}
+
+	if i := strings.Index(str, "-"); err == nil && i >= 0 {
+		v.PreRelease = str[i+1:]
+		str = str[:i]
+		err = checkIdentifiers(v.PreRelease, true)
+	}
+
+	if err == nil {
+		nums := strings.Split(str, ".")
+		if len(nums) > 3 {
+			err = fmt.Errorf("too many version components")
+		}
+
+		fields := []*int{&v.Major, &v.Minor, &v.Patch}
+		for i := 0; err == nil && i < len(nums); i++ {
+			*fields[i], err = parseNumeric(nums[i])
+		}
+		parts = len(nums)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("%w %q: %v", ErrInvalidVersion, input, err)
+	}
+	return
+}
+
+func parseNumeric(str string) (int, error) {
+	if str == "" {
+		return 0, fmt.Errorf("empty numeric identifier")
+	} else if len(str) > 1 && str[0] == '0' {
+		return 0, fmt.Errorf("numeric identifier %q has a leading zero", str)
+	}
+
+	for _, r := range str {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("%q is not a number", str)
+		}
+	}
+	return strconv.Atoi(str)
+}
+
+// checkIdentifiers makes sure that each dot separated identifier
+// is non-empty and only contains [0-9A-Za-z-].  Pre-release numeric
+// identifiers must not have leading zeros
+func checkIdentifiers(str string, preRelease bool) error {
+	for _, id := range strings.Split(str, ".") {
+		if id == "" {
+			return fmt.Errorf("empty identifier in %q", str)
+		}
+
+		numeric := true
+		for _, r := range id {
+			switch {
+			case '0' <= r && r <= '9':
+			case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', r == '-':
+				numeric = false
+			default:
+				return fmt.Errorf("invalid character %q in identifier %q", r, id)
+			}
+		}
+
+		if preRelease && numeric && len(id) > 1 && id[0] == '0' {
+			return fmt.Errorf("numeric identifier %q has a leading zero", id)
+		}
+	}
+	return nil
+}
+
+// ParseVersion parses a full semantic version such as 1.2.3,
+// v1.2.3, 1.0.0-rc.1 or 1.0.0+build.5
+func ParseVersion(str string) (v Version, err error) {
+	err = v.Set(str)
+	return
+}
+
+// Set will parse the string and populate
+// the appropriate Version fields.  The string must be
+// a complete semantic version, optionally prefixed with "v"
+func (v *Version) Set(str string) error {
+	version, parts, err := parseVersion(str)
+	if err == nil {
+		if parts == 3 {
+			*v = version
+		} else {
+			err = fmt.Errorf("%w %q: expected major.minor.patch", ErrInvalidVersion, str)
+		}
+	}
+	return err
+}
+
+// Bump returns the next version for the given release type which
+// must be one of "major", "minor", "patch" or "prerelease".  Bumping
+// a pre-release to a major, minor or patch release that it precedes
+// will simply drop the pre-release (ie 2.0.0-rc.1 -> 2.0.0).  The
+// "prerelease" type uses "rc" as the pre-release identifier
+func (v Version) Bump(release string) (next Version, err error) {
+	switch release {
+	case "major":
+		next = Version{Major: v.Major + 1}
+		if v.IsPreRelease() && v.Minor == 0 && v.Patch == 0 {
+			next.Major = v.Major
+		}
+	case "minor":
+		next = Version{Major: v.Major, Minor: v.Minor + 1}
+		if v.IsPreRelease() && v.Patch == 0 {
+			next.Minor = v.Minor
+		}
+	case "patch":
+		next = Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+		if v.IsPreRelease() {
+			next.Patch = v.Patch
+		}
+	case "prerelease":
+		next, err = v.BumpPreRelease("rc")
+	default:
+		err = fmt.Errorf("%w %q: expected major, minor, patch or prerelease", ErrInvalidBump, release)
+	}
+	return
+}
+
+// BumpPreRelease returns the next pre-release version using the
+// given identifier.  A release is bumped to a pre-release of the next
+// patch version (1.2.3 -> 1.2.4-rc.1) and an existing pre-release
+// with the same identifier has its number incremented
+// (1.2.4-rc.1 -> 1.2.4-rc.2).  An error is returned if the resulting
+// version would not have a higher precedence than v
+func (v Version) BumpPreRelease(id string) (next Version, err error) {
+	next = Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch, PreRelease: id + ".1"}
+	if !v.IsPreRelease() {
+		next.Patch++
+	} else if ids := strings.Split(v.PreRelease, "."); ids[0] == id && len(ids) > 1 {
+		if n, err := strconv.Atoi(ids[len(ids)-1]); err == nil {
+			ids[len(ids)-1] = strconv.Itoa(n + 1)
+		} else {
+			ids = append(ids, "1")
+		}
+		next.PreRelease = strings.Join(ids, ".")
+	}
+
+	if err = checkIdentifiers(next.PreRelease, true); err != nil {
+		err = fmt.Errorf("%w: %v", ErrInvalidBump, err)
+	} else if next.Compare(v) <= 0 {
+		err = fmt.Errorf("%w: %s does not follow %s", ErrInvalidBump, next.String(), v.String())
+	}
+	return
+}
+
+// MarshalJSON will convert the Version to a JSON string
+// such as "1.0.0"
+func (v *Version) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
+
+// UnmarshalJSON will parse a JSON string and populate
+// the Version fields.  Any input accepted by Set is valid
+func (v *Version) UnmarshalJSON(input []byte) error {
+	str := ""
+	err := json.Unmarshal(input, &str)
+	if err == nil {
+		err = v.Set(str)
+	}
+	return err
+}
+
+// comparator is a single operator/version pair such as ">=1.2.0"
+type comparator struct {
+	op      string
+	version Version
+}
+
+func (c comparator) check(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "!=":
+		return cmp != 0
+	}
+	return cmp == 0
+}
+
+// Constraint is a set of version ranges.  A version satisfies
+// the constraint if it satisfies every comparison in any one
+// of the ranges
+type Constraint struct {
+	str    string
+	ranges [][]comparator
+}
+
+// ParseConstraint parses a constraint string.  Comparisons
+// separated by whitespace (or commas) must all match and
+// ranges separated by "||" are alternatives.  The supported
+// comparisons are:
+//
+//	1.2.3, =1.2.3   exactly 1.2.3
+//	!=1.2.3         anything but 1.2.3
+//	>1.2 >=1.2      greater than (or equal)
+//	<2 <=2.1        less than (or equal)
+//	^1.4            compatible with 1.4 (>=1.4.0 <2.0.0)
+//	~1.4.2          patch updates only (>=1.4.2 <1.5.0)
+//
+// Partial versions are treated as ranges, so "1.2" matches any
+// 1.2.x release and "<2" excludes any 2.x pre-releases
+func ParseConstraint(str string) (Constraint, error) {
+	c := Constraint{str: str}
+	for _, alt := range strings.Split(str, "||") {
+		var comparators []comparator
+		for _, field := range strings.FieldsFunc(alt, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			cmps, err := parseComparison(field)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("%w %q: %v", ErrInvalidConstraint, str, err)
+			}
+			comparators = append(comparators, cmps...)
+		}
+
+		if len(comparators) == 0 {
+			return Constraint{}, fmt.Errorf("%w %q: empty range", ErrInvalidConstraint, str)
+		}
+		c.ranges = append(c.ranges, comparators)
+	}
+	return c, nil
+}
+
+// Check determines if the version satisfies the constraint
+func (c Constraint) Check(v Version) bool {
+	for _, r := range c.ranges {
+		matched := true
+		for _, cmp := range r {
+			if !cmp.check(v) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the constraint as it was originally parsed
+func (c Constraint) String() string { return c.str }
+
+// lowest returns the lowest version that is a member of the
+// pre-release set of v ("X.Y.Z-0") so that exclusive upper
+// bounds also exclude any pre-release of the bound
+func lowest(v Version) Version {
+	return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch, PreRelease: "0"}
+}
+
+// next increments the last specified component of a
+// partial version, ie 1 -> 2.0.0 and 1.2 -> 1.3.0
+func next(v Version, parts int) Version {
+	switch parts {
+	case 1:
+		return Version{Major: v.Major + 1}
+	case 2:
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	}
+	return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
+
+func parseComparison(str string) ([]comparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", "!=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(str, prefix) {
+			op = prefix
+			break
+		}
+	}
+
+	v, parts, err := parseVersion(strings.TrimPrefix(str, op))
+	if err != nil {
+		return nil, err
+	}
+
+	partial := parts < 3
+	switch op {
+	case "^":
+		upper := Version{Major: v.Major + 1}
+		if v.Major == 0 && parts > 1 {
+			if v.Minor != 0 || parts == 2 {
+				upper = Version{Minor: v.Minor + 1}
+			} else {
+				upper = Version{Patch: v.Patch + 1}
+			}
+		}
+		return []comparator{{">=", v}, {"<", lowest(upper)}}, nil
+	case "~":
+		if parts == 1 {
+			return []comparator{{">=", v}, {"<", lowest(next(v, 1))}}, nil
+		}
+		return []comparator{{">=", v}, {"<", lowest(next(v, 2))}}, nil
+	case ">":
+		if partial {
+			return []comparator{{">=", lowest(next(v, parts))}}, nil
+		}
+	case "<":
+		if partial {
+			return []comparator{{"<", lowest(v)}}, nil
+		}
+	case "<=":
+		if partial {
+			return []comparator{{"<", lowest(next(v, parts))}}, nil
+		}
+	case "", "=":
+		if partial {
+			return []comparator{{">=", v}, {"<", lowest(next(v, parts))}}, nil
+		}
+		op = "="
+	case "!=":
+		if partial {
+			return nil, fmt.Errorf("%q requires a complete version", str)
+		}
+	}
+	return []comparator{{op, v}}, nil
+}
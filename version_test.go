@@ -0,0 +1,354 @@
+package waffle
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+func mustParseVersion(t *testing.T, str string) Version {
+	t.Helper()
+	v, err := ParseVersion(str)
+	if err != nil {
+		t.Fatalf("ParseVersion(%q) unexpected error: %v", str, err)
+	}
+	return v
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Version
+		wantErr bool
+	}{
+		{input: "1.2.3", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{input: "v1.2.3", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{input: "0.0.0", want: Version{}},
+		{input: "10.20.30", want: Version{Major: 10, Minor: 20, Patch: 30}},
+		{input: "1.0.0-rc.1", want: Version{Major: 1, PreRelease: "rc.1"}},
+		{input: "1.0.0-alpha-a.b-c", want: Version{Major: 1, PreRelease: "alpha-a.b-c"}},
+		{input: "1.0.0-0A.is.legal", want: Version{Major: 1, PreRelease: "0A.is.legal"}},
+		{input: "1.0.0+build.5", want: Version{Major: 1, Build: "build.5"}},
+		{input: "1.0.0-alpha+001", want: Version{Major: 1, PreRelease: "alpha", Build: "001"}},
+		{input: "1.0.0+20211006.sha.5114f85", want: Version{Major: 1, Build: "20211006.sha.5114f85"}},
+
+		// leading zeros
+		{input: "01.2.3", wantErr: true},
+		{input: "1.02.3", wantErr: true},
+		{input: "1.2.03", wantErr: true},
+		{input: "1.2.3-01", wantErr: true},
+		{input: "1.2.3-rc.01", wantErr: true},
+
+		{input: "", wantErr: true},
+		{input: "1", wantErr: true},
+		{input: "1.2", wantErr: true},
+		{input: "1.2.3.4", wantErr: true},
+		{input: "1.2.x", wantErr: true},
+		{input: "-1.2.3", wantErr: true},
+		{input: "1.2.3-", wantErr: true},
+		{input: "1.2.3+", wantErr: true},
+		{input: "1.2.3-rc..1", wantErr: true},
+		{input: "1.2.3-rc_1", wantErr: true},
+		{input: "1.2.3+build+5", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := ParseVersion(test.input)
+			if test.wantErr {
+				if !errors.Is(err, ErrInvalidVersion) {
+					t.Errorf("Expected ErrInvalidVersion got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			} else if got != test.want {
+				t.Errorf("Wanted %+v got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	tests := []string{"1.2.3", "1.0.0-rc.1", "1.0.0+build.5", "1.0.0-alpha+001"}
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			v := mustParseVersion(t, test)
+			if got := v.String(); got != test {
+				t.Errorf("Wanted %q got %q", test, got)
+			}
+
+			if got := v.Tag(); got != "v"+test {
+				t.Errorf("Wanted tag %q got %q", "v"+test, got)
+			}
+		})
+	}
+}
+
+func TestVersionPrecedence(t *testing.T) {
+	// ordered lowest to highest, from https://semver.org/#spec-item-11
+	tests := [][]string{
+		{"1.0.0", "2.0.0", "2.1.0", "2.1.1"},
+		{"1.0.0-alpha", "1.0.0"},
+		{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0"},
+		{"1.9.0", "1.10.0", "1.11.0"},
+		{"1.0.0-2", "1.0.0-10", "1.0.0-a"},
+	}
+
+	for _, test := range tests {
+		versions := VersionList{}
+		for _, str := range test {
+			versions = append(versions, mustParseVersion(t, str))
+		}
+
+		for i := range versions {
+			for j := range versions {
+				want := 0
+				if i < j {
+					want = -1
+				} else if i > j {
+					want = 1
+				}
+
+				if got := versions[i].Compare(versions[j]); got != want {
+					t.Errorf("%s compared to %s wanted %d got %d", test[i], test[j], want, got)
+				}
+			}
+		}
+
+		reversed := VersionList{}
+		for i := len(versions) - 1; i >= 0; i-- {
+			reversed = append(reversed, versions[i])
+		}
+
+		sort.Sort(reversed)
+		for i := range reversed {
+			if reversed[i] != versions[i] {
+				t.Errorf("Sorted index %d wanted %s got %s", i, test[i], reversed[i].String())
+			}
+		}
+	}
+}
+
+func TestVersionBuildIgnored(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"1.0.0", "1.0.0+build.5"},
+		{"1.0.0+build.5", "1.0.0+build.6"},
+		{"1.0.0-alpha", "1.0.0-alpha+001"},
+		{"1.0.0+20130313144700", "1.0.0+exp.sha.5114f85"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			a, b := mustParseVersion(t, test.a), mustParseVersion(t, test.b)
+			if got := a.Compare(b); got != 0 {
+				t.Errorf("Wanted 0 got %d", got)
+			}
+		})
+	}
+}
+
+func TestVersionBump(t *testing.T) {
+	tests := []struct {
+		version string
+		release string
+		want    string
+		wantErr error
+	}{
+		{"1.2.3", "major", "2.0.0", nil},
+		{"1.2.3", "minor", "1.3.0", nil},
+		{"1.2.3", "patch", "1.2.4", nil},
+		{"1.2.3", "prerelease", "1.2.4-rc.1", nil},
+		{"1.2.3+build.5", "patch", "1.2.4", nil},
+		{"0.1.0", "major", "1.0.0", nil},
+
+		// pre-releases are released as the version they precede
+		{"2.0.0-rc.1", "major", "2.0.0", nil},
+		{"2.1.0-rc.1", "major", "3.0.0", nil},
+		{"1.3.0-rc.1", "minor", "1.3.0", nil},
+		{"1.3.1-rc.1", "minor", "1.4.0", nil},
+		{"1.2.4-rc.1", "patch", "1.2.4", nil},
+		{"1.2.4-rc.1", "prerelease", "1.2.4-rc.2", nil},
+		{"1.2.4-rc.9", "prerelease", "1.2.4-rc.10", nil},
+
+		{"1.2.3", "", "", ErrInvalidBump},
+		{"1.2.3", "build", "", ErrInvalidBump},
+	}
+
+	for _, test := range tests {
+		t.Run(test.version+" "+test.release, func(t *testing.T) {
+			got, err := mustParseVersion(t, test.version).Bump(test.release)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Errorf("Wanted error %v got %v", test.wantErr, err)
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			} else if got != mustParseVersion(t, test.want) {
+				t.Errorf("Wanted %s got %s", test.want, got.String())
+			}
+		})
+	}
+}
+
+func TestVersionBumpPreRelease(t *testing.T) {
+	tests := []struct {
+		version string
+		id      string
+		want    string
+		wantErr error
+	}{
+		{"1.2.3", "rc", "1.2.4-rc.1", nil},
+		{"1.2.3", "beta", "1.2.4-beta.1", nil},
+		{"1.2.4-rc.1", "rc", "1.2.4-rc.2", nil},
+		{"1.2.4-rc", "rc", "1.2.4-rc.1", nil},
+		{"1.2.4-rc.x", "rc", "1.2.4-rc.x.1", nil},
+		{"1.2.4-alpha.3", "beta", "1.2.4-beta.1", nil},
+
+		// the next version must have a higher precedence
+		{"1.2.4-rc.1", "beta", "", ErrInvalidBump},
+		{"1.2.4-rc.1", "alpha", "", ErrInvalidBump},
+
+		{"1.2.3", "", "", ErrInvalidBump},
+		{"1.2.3", "01", "", ErrInvalidBump},
+		{"1.2.3", "r_c", "", ErrInvalidBump},
+	}
+
+	for _, test := range tests {
+		t.Run(test.version+" "+test.id, func(t *testing.T) {
+			got, err := mustParseVersion(t, test.version).BumpPreRelease(test.id)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Errorf("Wanted error %v got %v", test.wantErr, err)
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			} else if got != mustParseVersion(t, test.want) {
+				t.Errorf("Wanted %s got %s", test.want, got.String())
+			}
+		})
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"=1.2.3", "1.2.3+build.5", true},
+		{"!=1.2.3", "1.2.3", false},
+		{"!=1.2.3", "1.2.4", true},
+		{">1.2.3", "1.2.4", true},
+		{">1.2.3", "1.2.3", false},
+		{">=1.2.3", "1.2.3", true},
+		{"<1.2.3", "1.2.3-rc.1", true},
+		{"<=1.2.3", "1.2.3", true},
+		{"<=1.2.3", "1.2.4-rc.1", false},
+
+		// partial versions are ranges
+		{"1.2", "1.2.0", true},
+		{"1.2", "1.2.9", true},
+		{"1.2", "1.3.0", false},
+		{"1", "1.9.9", true},
+		{"1", "2.0.0", false},
+		{">1.2", "1.2.9", false},
+		{">1.2", "1.3.0", true},
+		{"<=2.1", "2.1.9", true},
+		{"<=2.1", "2.2.0-rc.1", false},
+		{"<2", "1.9.9", true},
+		{"<2", "2.0.0-rc.1", false},
+		{"<2", "2.0.0", false},
+
+		// caret
+		{"^1.4", "1.4.0", true},
+		{"^1.4", "1.9.9", true},
+		{"^1.4", "1.3.9", false},
+		{"^1.4", "2.0.0-rc.1", false},
+		{"^1.4", "2.0.0", false},
+		{"^1.4.2", "1.4.1", false},
+		{"^1", "1.0.0", true},
+		{"^1", "2.0.0", false},
+		{"^0.2.3", "0.2.3", true},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.2.2", false},
+		{"^0.2.3", "0.3.0-rc.1", false},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.2", "0.2.0", true},
+		{"^0.2", "0.2.9", true},
+		{"^0.2", "0.3.0", false},
+		{"^0.0.3", "0.0.3", true},
+		{"^0.0.3", "0.0.4-rc.1", false},
+		{"^0.0.3", "0.0.4", false},
+		{"^0.0", "0.0.9", true},
+		{"^0.0", "0.1.0", false},
+		{"^0", "0.9.9", true},
+		{"^0", "1.0.0", false},
+
+		// tilde
+		{"~1.4.2", "1.4.2", true},
+		{"~1.4.2", "1.4.9", true},
+		{"~1.4.2", "1.4.1", false},
+		{"~1.4.2", "1.5.0", false},
+		{"~1.4", "1.4.0", true},
+		{"~1.4", "1.5.0", false},
+		{"~1", "1.9.0", true},
+		{"~1", "2.0.0", false},
+
+		// every comparison in a range must match
+		{">=1.2.0 <2.0.0", "1.5.0", true},
+		{">=1.2.0 <2.0.0", "2.0.0", false},
+		{">=1.2.0, <2.0.0", "1.1.0", false},
+		{">=1.2.0,<2.0.0", "1.2.0", true},
+
+		// any range may match
+		{"^1.2 || ^3.0", "1.5.0", true},
+		{"^1.2 || ^3.0", "2.0.0", false},
+		{"^1.2 || ^3.0", "3.1.0", true},
+		{"<1 || >=2", "1.5.0", false},
+		{"<1 || >=2", "0.5.0", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.constraint+" "+test.version, func(t *testing.T) {
+			c, err := ParseConstraint(test.constraint)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if c.String() != test.constraint {
+				t.Errorf("Wanted string %q got %q", test.constraint, c.String())
+			}
+
+			if got := c.Check(mustParseVersion(t, test.version)); got != test.want {
+				t.Errorf("Wanted %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"||",
+		"^1.2 ||",
+		"1.2.3.4",
+		"01.2",
+		">=1.x",
+		"!=1.2",
+		"^",
+		"~v",
+	}
+
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			_, err := ParseConstraint(test)
+			if !errors.Is(err, ErrInvalidConstraint) {
+				t.Errorf("Expected ErrInvalidConstraint got %v", err)
+			}
+		})
+	}
+}